@@ -1,8 +1,14 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
 
+	"github.com/mitchellh/go-homedir"
 	"github.com/valyala/fasttemplate"
 )
 
@@ -11,62 +17,146 @@ type Action struct {
 	printer *Printer
 }
 
+// outsideWorkTree print current directory, and return ErrOutsideWorkTree
+func (a *Action) outsideWorkTree() error {
+	current, err := os.Getwd()
+	a.printer.Printf("outside work tree. %s %v\n", current, err)
+	return ErrOutsideWorkTree
+}
+
 // ShowUser
 func (a *Action) ShowUser(c *Context) error {
-	git := &Git{}
-	if !git.IsInsideWorkTree() {
-		current, err := os.Getwd()
-		a.printer.Printf("outside work tree. %s %v\n", current, err)
-		return nil
+	git := c.git("")
+	inside, err := git.IsInsideWorkTree()
+	if err != nil {
+		return err
+	}
+	if !inside {
+		return a.outsideWorkTree()
 	}
 
-	url := git.GetRemoteOriginURL()
-	if url == "" {
-		a.printer.Println("no remote origin url. set your remote origin url!")
-		return nil
+	repo, err := c.repository(git)
+	if err != nil {
+		return err
+	}
+	match := c.Users.TakeByRepository(repo)
+	if match == nil {
+		if len(repo.Remotes) == 0 {
+			a.printer.Println("no remote url. set your remote url or `git-user set --path`!")
+			return c.noMatch()
+		}
+		a.printer.Println("no git-user config. `git-user set name email`")
+		return c.noMatch()
 	}
 
-	user := c.Users.TakeByURL(url)
-	if user == nil {
+	a.printer.PrintMatchedUser(match)
+
+	return nil
+}
+
+// Explain show all users matched current repository, ranked with the reason
+func (a *Action) Explain(c *Context) error {
+	git := c.git("")
+	inside, err := git.IsInsideWorkTree()
+	if err != nil {
+		return err
+	}
+	if !inside {
+		return a.outsideWorkTree()
+	}
+
+	repo, err := c.repository(git)
+	if err != nil {
+		return err
+	}
+	matches := c.Users.MatchRepository(repo)
+	if len(matches) == 0 {
+		if len(repo.Remotes) == 0 {
+			a.printer.Println("no remote url. set your remote url or `git-user set --path`!")
+			return c.noMatch()
+		}
 		a.printer.Println("no git-user config. `git-user set name email`")
-		return nil
+		return c.noMatch()
 	}
 
-	a.printer.PrintUser(user)
+	winner := matches[0]
+	for i, match := range matches {
+		a.printer.Printf("#%d  ", i+1)
+		a.printer.PrintUser(match.User)
+		if match.Remote != nil {
+			a.printer.Printf("    Remote: %s  URL: %s  Priority: %d\n", match.Remote.Name, match.Remote.URL, match.User.Priority)
+		} else {
+			a.printer.Printf("    Path: %s  Priority: %d\n", match.Path, match.User.Priority)
+		}
+		if i == 0 {
+			a.printer.Println("    selected")
+			continue
+		}
+		_, reason := winner.Compare(match, repo.Remotes)
+		a.printer.Printf("    lost to #1: %s\n", reason)
+	}
 
 	return nil
 }
 
 func (a *Action) SetUser(c *Context) error {
 	option := c.Option.Set
-	if err := option.Args.Valid(); err != nil {
+	if option.Profile == "" {
+		if err := option.Args.Valid(); err != nil {
+			return err
+		}
+	}
+	if err := option.Valid(); err != nil {
 		return err
 	}
 
 	url := option.URL
-	if url == "" {
-		git := &Git{}
-		if !git.IsInsideWorkTree() {
-			current, err := os.Getwd()
-			a.printer.Printf("outside work tree. %s %v\n", current, err)
+	if option.Path != "" {
+		url = option.Path
+	}
+	if url == "" && !option.Structured() {
+		git := c.git("")
+		inside, err := git.IsInsideWorkTree()
+		if err != nil {
+			return err
+		}
+		if !inside {
+			err := a.outsideWorkTree()
 			a.printer.Println("required `--url` option or inside work tree")
-			return nil
+			return err
 		}
 
-		url = git.GetRemoteOriginURL()
-		if url == "" {
-			a.printer.Println("no remote origin url")
-			a.printer.Println("required `--url` option or set your remote origin url!")
-			return nil
+		remotes, err := git.GetRemotes()
+		if err != nil {
+			return err
+		}
+		remotes = remotes.Prioritize(c.remotePriority())
+		if len(remotes) == 0 {
+			return errors.New("no remote url. required `--url` option or set your remote url!")
 		}
+		url = remotes[0].URL
 	}
 
-	user := c.Users.Set(
-		url,
-		option.Args.Name,
-		option.Args.Email,
-		option.Args.SigningKey,
-	)
+	user := option.User(url)
+	if option.Profile != "" {
+		profile := c.Profiles.Get(option.Profile)
+		if profile == nil {
+			return fmt.Errorf("profile %s is not found. `git-user profile add`", option.Profile)
+		}
+		user.SetIdentity(profile.Identity)
+	}
+	if err := user.Valid(); err != nil {
+		return err
+	}
+	if !option.Force {
+		if err := validKeys(user); err != nil {
+			return err
+		}
+	}
+	if option.Profile == "" {
+		user.Profile = c.Profiles.ForIdentity(user.Identity()).Profile
+	}
+	user = c.Users.Put(user)
 
 	if err := c.SaveConfig(); err != nil {
 		return err
@@ -77,13 +167,31 @@ func (a *Action) SetUser(c *Context) error {
 	return nil
 }
 
+// validKeys check signing key and ssh key exist
+func validKeys(user *User) error {
+	for _, valid := range []func() error{user.ValidSigningKey, user.ValidSSHKey} {
+		if err := valid(); err != nil {
+			return fmt.Errorf("%v. use `--force` to save anyway", err)
+		}
+	}
+	return nil
+}
+
 func (a *Action) DeleteUser(c *Context) error {
 	hash := c.Option.Delete.Args.Hash
+	if user := c.Users.TakeByHash(hash); user != nil {
+		if file, ok := c.sharedFile(user); ok {
+			return fmt.Errorf("rule %s is defined in %s. edit the file", user.Rule(), file)
+		}
+	}
 
 	user := c.Users.Delete(hash)
 	if user == nil {
 		a.printer.Printf("not found user by %s\n", hash)
-		return nil
+		return c.noMatch()
+	}
+	if err := c.SaveConfig(); err != nil {
+		return err
 	}
 
 	a.printer.PrintUser(user)
@@ -92,98 +200,694 @@ func (a *Action) DeleteUser(c *Context) error {
 }
 
 func (a *Action) ShowLocalUser(c *Context) error {
-	git := &Git{}
-	if !git.IsInsideWorkTree() {
-		current, err := os.Getwd()
-		a.printer.Printf("outside work tree. %s %v\n", current, err)
-		return nil
+	git := c.git("")
+	inside, err := git.IsInsideWorkTree()
+	if err != nil {
+		return err
+	}
+	if !inside {
+		return a.outsideWorkTree()
 	}
 
-	user := &User{
-		URL:        git.GetRemoteOriginURL(),
-		Name:       git.GetLocalUserName(),
-		Email:      git.GetLocalUserEmail(),
-		SigningKey: git.GetLocalUserSigningKey(),
+	remotes, err := git.GetRemotes()
+	if err != nil {
+		return err
+	}
+	identity, err := gitIdentity(git.GetLocalConfig)
+	if err != nil {
+		return err
+	}
+	user := &User{}
+	user.SetIdentity(identity)
+	if remotes = remotes.Prioritize(c.remotePriority()); len(remotes) > 0 {
+		user.URL = remotes[0].URL
 	}
 
 	a.printer.PrintUser(user)
 	return nil
 }
 
+// gitIdentity read user.name, user.email and user.signingkey by get. unset key is empty
+func gitIdentity(get func(key string) (string, error)) (Identity, error) {
+	var identity Identity
+	for key, value := range map[string]*string{
+		"user.name":       &identity.Name,
+		"user.email":      &identity.Email,
+		"user.signingkey": &identity.SigningKey,
+	} {
+		var err error
+		if *value, err = get(key); err != nil && !IsNotFound(err) {
+			return identity, err
+		}
+	}
+	return identity, nil
+}
+
 func (a *Action) ListUsers(c *Context) error {
 	a.printer.PrintUsers(c.Users)
 	return nil
 }
 
 func (a *Action) SyncGitUserToLocal(c *Context) error {
-	git := &Git{}
-	if !git.IsInsideWorkTree() {
-		current, err := os.Getwd()
-		a.printer.Printf("outside work tree. %s %v\n", current, err)
-		return nil
+	if c.Option.Sync.Recursive != "" {
+		return a.syncRecursive(c)
 	}
 
-	url := git.GetRemoteOriginURL()
-	if url == "" {
-		a.printer.Println("no remote origin url. set your remote origin url!")
-		return nil
+	git := c.git("")
+	inside, err := git.IsInsideWorkTree()
+	if err != nil {
+		return err
+	}
+	if !inside {
+		return a.outsideWorkTree()
+	}
+
+	repo, err := c.repository(git)
+	if err != nil {
+		return err
+	}
+	result := SyncRepository(git, repo, c.Users, c.Option.Sync.DryRun)
+	if result.Match == nil && len(repo.Remotes) == 0 {
+		a.printer.Println("no remote url. set your remote url or `git-user set --path`!")
+		return c.noMatch()
+	}
+
+	a.printer.PrintMatch(result.Match)
+	a.printer.PrintSyncResult(result)
+	if result.Status == SyncError {
+		// errors are printed with result. exit status is of the first one
+		return &ExitError{Code: exitStatus(result.Errors[0]), Err: fmt.Errorf("%w: %v", ErrSyncFailed, result.Errors[0])}
+	}
+	if result.Match == nil {
+		return c.noMatch()
 	}
 
-	user := c.Users.TakeByURL(url)
+	return nil
+}
+
+// syncRecursive sync all work trees under directory, and print summary
+func (a *Action) syncRecursive(c *Context) error {
+	option := c.Option.Sync
+	root, err := homedir.Expand(option.Recursive)
+	if err != nil {
+		return err
+	}
+	paths, err := FindWorkTrees(root)
+	if err != nil {
+		return err
+	}
+
+	results := SyncWorkTrees(paths, option.Jobs, func(path string) *SyncResult {
+		git := c.git(path)
+		repo, err := c.repository(git)
+		if err != nil {
+			return &SyncResult{Path: path, Status: SyncError, Errors: []error{err}}
+		}
+		return SyncRepository(git, repo, c.Users, option.DryRun)
+	})
+
+	a.printer.PrintSyncResults(results)
 
-	if user != nil && user.Name != "" {
-		n := git.GetLocalUserName()
-		if user.Name != n {
-			if err := git.SetLocalUserName(user.Name); err != nil {
-				a.printer.Println(err.Error())
+	counts := map[string]int{}
+	for _, result := range results {
+		counts[result.Status]++
+	}
+	if counts[SyncError] > 0 {
+		return fmt.Errorf("failed to sync %d work trees", counts[SyncError])
+	}
+	if counts[SyncUnmatched] > 0 {
+		return c.noMatch()
+	}
+
+	return nil
+}
+
+// ExportIncludeIf write identity config files and includeIf block of global git config
+func (a *Action) ExportIncludeIf(c *Context) error {
+	option := c.Option.ExportIncludeIf
+	dir, err := homedir.Expand(option.Dir)
+	if err != nil {
+		return err
+	}
+	gitconfig, err := homedir.Expand(option.GitConfig)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	written := map[string]bool{}
+	var includes [][2]string
+	for _, user := range SortForInclude(c.Users) {
+		conditions, err := user.IncludeIfConditions()
+		if err != nil {
+			a.printer.Printf("skip %s: %v\n", user.Rule(), err)
+			continue
+		}
+
+		file := filepath.Join(dir, IdentityFileName(user))
+		if !written[file] {
+			if _, err := os.Stat(file); err == nil && !IsIdentityFile(file) {
+				a.printer.Printf("skip %s: %s is not written by git-user\n", user.Rule(), file)
+				continue
+			}
+			data, err := IdentityFile(user)
+			if err != nil {
+				return err
+			}
+			if err := writeFileAtomic(file, data, 0644); err != nil {
+				return err
 			}
+			written[file] = true
 		}
-	} else {
-		if err := git.UnsetLocalUserName(); err != nil {
-			a.printer.Println(err.Error())
+		for _, condition := range conditions {
+			includes = append(includes, [2]string{condition, file})
 		}
+		a.printer.Printf("export %s -> %s\n", user.Rule(), file)
+	}
+
+	stale, err := filepath.Glob(filepath.Join(dir, "*.gitconfig"))
+	if err != nil {
+		return err
+	}
+	for _, file := range stale {
+		// only identity files written by git-user, never e.g. ~/.gitconfig
+		if !written[file] && IsIdentityFile(file) {
+			if err := os.Remove(file); err != nil {
+				return err
+			}
+		}
+	}
+
+	// under .gitconfig.lock like `git config --global`. keep symlink of e.g. dotfiles repository
+	err = replaceGitConfigFile(resolveSymlink(gitconfig), func(current []byte) ([]byte, error) {
+		return []byte(ReplaceManagedBlock(string(current), IncludeIfBlock(includes))), nil
+	})
+	if err != nil {
+		return err
 	}
+	a.printer.Printf("update %s\n", gitconfig)
 
-	if user != nil && user.Email != "" {
-		e := git.GetLocalUserEmail()
-		if user.Email != e {
-			if err := git.SetLocalUserEmail(user.Email); err != nil {
-				a.printer.Println(err.Error())
+	return nil
+}
+
+// InstallHook install pre-commit (and pre-push) hook into current repository or global core.hooksPath
+func (a *Action) InstallHook(c *Context) error {
+	option := c.Option.Hook.Install
+	git := c.git("")
+
+	var dir string
+	if option.Global {
+		var err error
+		dir, err = git.GetGlobalConfig("core.hooksPath")
+		if err != nil && !IsNotFound(err) {
+			return err
+		}
+		if dir == "" {
+			dir = defaultGlobalHooksPath
+			if err := git.SetGlobalConfig("core.hooksPath", dir); err != nil {
+				return err
 			}
+			a.printer.Printf("set global core.hooksPath %s\n", dir)
 		}
 	} else {
-		if err := git.UnsetLocalUserEmail(); err != nil {
-			a.printer.Println(err.Error())
+		inside, err := git.IsInsideWorkTree()
+		if err != nil {
+			return err
+		}
+		if !inside {
+			err := a.outsideWorkTree()
+			a.printer.Println("required `--global` option or inside work tree")
+			return err
+		}
+		if dir, err = git.GetGitPath("hooks"); err != nil {
+			return err
 		}
 	}
+	dir, err := homedir.Expand(dir)
+	if err != nil {
+		return err
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		executable = "git-user"
+	}
+
+	names := []string{"pre-commit"}
+	if option.PrePush {
+		names = append(names, "pre-push")
+	}
+	for _, name := range names {
+		chained, err := InstallHook(dir, name, HookScript(name, executable, option.Fix, option.Global))
+		if err != nil {
+			return err
+		}
+		a.printer.Printf("install %s\n", filepath.Join(dir, name))
+		if chained {
+			a.printer.Printf("chain existing hook %s\n", filepath.Join(dir, name+hookChainedSuffix))
+		}
+	}
+
+	return nil
+}
 
-	if user != nil && user.SigningKey != "" {
-		s := git.GetLocalUserSigningKey()
-		if user.SigningKey != s {
-			if err := git.SetLocalUserSigningKey(user.SigningKey); err != nil {
-				a.printer.Println(err.Error())
+// CheckHook reject commit when git user differs from git-user. with fix, sync it before rejecting
+func (a *Action) CheckHook(c *Context) error {
+	git := c.git("")
+	inside, err := git.IsInsideWorkTree()
+	if err != nil {
+		return err
+	}
+	if !inside {
+		if c.Option.Strict {
+			return a.outsideWorkTree()
+		}
+		return nil
+	}
+
+	repo, err := c.repository(git)
+	if err != nil {
+		return err
+	}
+	match := c.Users.TakeByRepository(repo)
+	if match == nil {
+		if c.Option.Strict {
+			a.printer.Println("git-user: no git-user config matched")
+		}
+		return c.noMatch()
+	}
+
+	// every managed key is checked, so that e.g. user.signingkey of another identity is rejected
+	keys := append([]string{}, managedConfigKeys...)
+	want := map[string]string{}
+	for _, entry := range match.User.GitConfig() {
+		key := canonicalConfigKey(entry.Key)
+		if _, ok := want[key]; !ok && !contains(keys, entry.Key) {
+			keys = append(keys, entry.Key)
+		}
+		want[key] = entry.Value
+	}
+	var diffs []string
+	for _, key := range keys {
+		value := want[canonicalConfigKey(key)]
+		if value == "" {
+			// must be unset locally. global one, e.g. user.signingkey of personal identity, is not checked
+			current, err := git.GetLocalConfig(key)
+			if err != nil && !IsNotFound(err) {
+				return err
+			}
+			if err == nil {
+				diffs = append(diffs, fmt.Sprintf("%s is %q, want unset", key, current))
 			}
+			continue
 		}
+		current, err := git.GetConfig(key)
+		if err != nil && !IsNotFound(err) {
+			return err
+		}
+		if IsNotFound(err) && value == "false" && unsetIsFalse(key) {
+			continue
+		}
+		if current != value {
+			diffs = append(diffs, fmt.Sprintf("%s is %q, want %q", key, current, value))
+		}
+	}
+	if len(diffs) == 0 {
+		return nil
+	}
+
+	for _, diff := range diffs {
+		a.printer.Println("git-user: " + diff)
+	}
+	if c.Option.Hook.Check.Fix {
+		syncAction := &Action{
+			printer: NewPrinter(PrintDefault, &nullIO{}),
+		}
+		if err := syncAction.SyncGitUserToLocal(c); err != nil {
+			// result of sync is not printed
+			a.printer.Println("git-user: " + err.Error())
+			return err
+		}
+		return errors.New("git-user fixed local git user. run again")
+	}
+	return errors.New("git-user rejected. run `git-user sync`")
+}
+
+// Audit report commits whose author or committer email differs from git-user. with fix, rewrite unpushed commits
+func (a *Action) Audit(c *Context) error {
+	option := c.Option.Audit
+	git := c.git("")
+	inside, err := git.IsInsideWorkTree()
+	if err != nil {
+		return err
+	}
+	if !inside {
+		return a.outsideWorkTree()
+	}
+
+	repo, err := c.repository(git)
+	if err != nil {
+		return err
+	}
+	match := c.Users.TakeByRepository(repo)
+	if match == nil {
+		a.printer.Println("no git-user config. `git-user set name email`")
+		return c.noMatch()
+	}
+	user := match.User
+
+	args := []string{option.Range}
+	if option.Range == "" {
+		upstream, err := git.HasUpstream()
+		if err != nil {
+			return err
+		}
+		if upstream {
+			args = []string{"@{upstream}..HEAD"}
+		} else {
+			args = []string{"HEAD", "--not", "--remotes"}
+		}
+	}
+	commits, err := git.GetCommits(args...)
+	if err != nil {
+		return err
+	}
+
+	var wrong []*Commit
+	for _, commit := range commits {
+		if !commit.Matches(user.Email) {
+			wrong = append(wrong, commit)
+		}
+	}
+	for _, commit := range wrong {
+		a.printer.Printf("%.7s  Author: %s <%s>  Committer: %s <%s>  %s\n",
+			commit.Hash, commit.AuthorName, commit.AuthorEmail, commit.CommitterName, commit.CommitterEmail, commit.Subject)
+	}
+	a.printer.Printf("%d of %d commits do not match %s\n", len(wrong), len(commits), user.Email)
+	if len(wrong) == 0 {
+		return nil
+	}
+	if !option.Fix {
+		return errors.New("git-user audit failed")
+	}
+
+	base, revision := "", "HEAD"
+	parent, err := git.HasParent(wrong[0].Hash)
+	if err != nil {
+		return err
+	}
+	if parent {
+		base = wrong[0].Hash + "^"
+		revision = base + "..HEAD"
+	}
+	rewriting, err := git.GetCommits(revision)
+	if err != nil {
+		return err
+	}
+	for _, commit := range rewriting {
+		pushed, err := git.IsPushed(commit.Hash)
+		if err != nil {
+			return err
+		}
+		if pushed {
+			return fmt.Errorf("%.7s is already pushed. not rewritten", commit.Hash)
+		}
+	}
+
+	if err := git.RewriteCommits(base, user.Name, user.Email); err != nil {
+		return err
+	}
+	a.printer.Printf("rewrite %d commits with %s <%s>\n", len(rewriting), user.Name, user.Email)
+
+	return nil
+}
+
+// AddProfile add named identity
+func (a *Action) AddProfile(c *Context) error {
+	option := c.Option.Profile.Add
+	if err := option.Args.Valid(); err != nil {
+		return err
+	}
+	if err := option.IdentityOption.Valid(option.Args.SigningKey); err != nil {
+		return err
+	}
+	if c.Profiles.Get(option.Args.Profile) != nil {
+		return fmt.Errorf("profile %s already exists. `git-user profile edit`", option.Args.Profile)
+	}
+
+	profile := &Profile{
+		Profile:  option.Args.Profile,
+		Identity: option.Identity(option.Args.Name, option.Args.Email, option.Args.SigningKey),
+	}
+	if err := profile.Valid(); err != nil {
+		return err
+	}
+	if !option.Force {
+		user := &User{}
+		user.SetIdentity(profile.Identity)
+		if err := validKeys(user); err != nil {
+			return err
+		}
+	}
+	c.Profiles.Put(profile)
+
+	if err := c.SaveConfig(); err != nil {
+		return err
+	}
+
+	a.printer.PrintProfiles(Profiles{profile}, c.Users)
+	return nil
+}
+
+// EditProfile replace identity of profile, or rename it
+func (a *Action) EditProfile(c *Context) error {
+	option := c.Option.Profile.Edit
+	profile := c.Profiles.Get(option.Args.Profile)
+	if profile == nil {
+		return fmt.Errorf("profile %s is not found", option.Args.Profile)
+	}
+	if file, ok := c.sharedFile(profile); ok {
+		if option.Rename != "" {
+			return fmt.Errorf("profile %s is defined in %s. edit the file", profile.Profile, file)
+		}
+		// profile of config file overrides profile of drop-in file
+		override := *profile
+		profile = c.Profiles.Put(&override)
+	}
+
+	if option.Args.Name != "" || option.Args.Email != "" || !option.IdentityOption.Empty() {
+		if err := option.Args.Valid(); err != nil {
+			return err
+		}
+		if err := option.IdentityOption.Valid(option.Args.SigningKey); err != nil {
+			return err
+		}
+		identity := option.Identity(option.Args.Name, option.Args.Email, option.Args.SigningKey)
+		if !option.Force {
+			user := &User{}
+			user.SetIdentity(identity)
+			if err := validKeys(user); err != nil {
+				return err
+			}
+		}
+		profile.Identity = identity
+	} else if option.Rename == "" {
+		return errors.New("required name and email arguments, or rename option")
+	}
+	if option.Rename != "" {
+		if err := c.Profiles.Rename(c.Users, profile.Profile, option.Rename); err != nil {
+			return err
+		}
+	}
+	for _, user := range c.Users.ByProfile(profile.Profile) {
+		user.SetIdentity(profile.Identity)
+	}
+
+	if err := c.SaveConfig(); err != nil {
+		return err
+	}
+
+	a.printer.PrintProfiles(Profiles{profile}, c.Users)
+	return nil
+}
+
+// RemoveProfile remove profile not referenced by rules
+func (a *Action) RemoveProfile(c *Context) error {
+	name := c.Option.Profile.Remove.Args.Profile
+	if profile := c.Profiles.Get(name); profile != nil {
+		if file, ok := c.sharedFile(profile); ok {
+			return fmt.Errorf("profile %s is defined in %s. edit the file", name, file)
+		}
+	}
+	if users := c.Users.ByProfile(name); len(users) > 0 {
+		a.printer.PrintUsers(users)
+		return fmt.Errorf("profile %s is referenced by %d rules. delete them first", name, len(users))
+	}
+
+	profile := c.Profiles.Delete(name)
+	if profile == nil {
+		a.printer.Printf("not found profile %s\n", name)
+		return c.noMatch()
+	}
+	if err := c.SaveConfig(); err != nil {
+		return err
+	}
+
+	a.printer.PrintProfiles(Profiles{profile}, c.Users)
+	return nil
+}
+
+// ListProfiles show all profiles
+func (a *Action) ListProfiles(c *Context) error {
+	a.printer.PrintProfiles(c.Profiles, c.Users)
+	return nil
+}
+
+// ConfigPath show config files in load order
+func (a *Action) ConfigPath(c *Context) error {
+	path, err := c.configPath()
+	if err != nil {
+		return err
+	}
+
+	var rows [][]string
+	for _, file := range c.Files {
+		if file == path {
+			continue
+		}
+		rows = append(rows, []string{file, "drop-in, read only"})
+	}
+	if len(c.Files) > 0 && c.Files[len(c.Files)-1] == path {
+		rows = append(rows, []string{path, "config"})
 	} else {
-		if err := git.UnsetLocalUserSigningKey(); err != nil {
-			a.printer.Println(err.Error())
+		rows = append(rows, []string{path, "config, not found"})
+	}
+	a.printer.PrintTable(rows)
+	return nil
+}
+
+// Import import shared rules and profiles, and report result per rule
+func (a *Action) Import(c *Context) error {
+	option := c.Option.Import
+	path, err := sharedConfigPath(option.Args.Path)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	doc, err := decodeConfig(data, configFormat(path))
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	git := c.git("")
+	identity, err := gitIdentity(git.GetGlobalConfig)
+	if err != nil {
+		return err
+	}
+	if err := RenderTemplates(doc, NewTemplateData(identity)); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	results, err := Import(&c.Profiles, &c.Users, doc, option.Mode)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	counts := map[string]int{}
+	rows := make([][]string, len(results))
+	for i, result := range results {
+		counts[result.Status]++
+		rows[i] = []string{result.Rule, result.Status, result.Detail}
+	}
+	a.printer.PrintTable(rows)
+	a.printer.Printf("%d added, %d unchanged, %d replaced, %d conflict\n",
+		counts[ImportAdded], counts[ImportUnchanged], counts[ImportReplaced], counts[ImportConflict])
+	if option.DryRun {
+		a.printer.Println("dry-run. nothing is written")
+		return nil
+	}
+
+	return c.SaveConfig()
+}
+
+// Export write rules and profiles into shared file
+func (a *Action) Export(c *Context) error {
+	option := c.Option.Export
+	doc := ExportDocument(c.Users, c.Profiles, option.Filter)
+	if option.File == "" {
+		data, err := encodeConfig(doc, option.Format, nil)
+		if err != nil {
+			return err
 		}
+		a.printer.Printf("%s", data)
+		return nil
+	}
+
+	path, err := homedir.Expand(option.File)
+	if err != nil {
+		return err
 	}
+	previous, _ := ioutil.ReadFile(path)
+	data, err := encodeConfig(doc, configFormat(path), previous)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		return err
+	}
+	a.printer.Printf("export %d rules and %d profiles into %s\n", len(doc.Users), len(doc.Profiles), path)
+	return nil
+}
 
+// Doctor validate all users and report broken ones
+func (a *Action) Doctor(c *Context) error {
+	sort.Sort(c.Users)
+	broken := 0
+	for _, user := range c.Users {
+		errs := user.Diagnose()
+		if len(errs) == 0 {
+			continue
+		}
+		broken++
+		a.printer.PrintUser(user)
+		for _, err := range errs {
+			a.printer.Printf("    %v\n", err)
+		}
+	}
+	a.printer.Printf("%d of %d git-user are broken\n", broken, len(c.Users))
+	if broken > 0 {
+		return errors.New("git-user doctor failed")
+	}
 	return nil
 }
 
 func (a *Action) Print(c *Context) error {
-	syncAction := &Action{
-		printer: NewPrinter(PrintDefault, &nullIO{}),
+	git := c.git("")
+	inside, err := git.IsInsideWorkTree()
+	if err != nil {
+		return err
 	}
-
-	if err := syncAction.SyncGitUserToLocal(c); err != nil {
+	if !inside {
+		if c.Option.Strict {
+			return a.outsideWorkTree()
+		}
 		return nil
 	}
 
-	git := &Git{}
-	user := c.Users.TakeByURL(git.GetRemoteOriginURL())
+	repo, err := c.repository(git)
+	if err != nil {
+		return err
+	}
+	// keys are validated by set, sync and doctor. print runs on every prompt
+	result := syncRepository(git, repo, c.Users, false, false)
+	if result.Match == nil {
+		return c.noMatch()
+	}
+	user := result.Match.User
 
 	temp := fasttemplate.New(c.Option.Print.Format, "{", "}")
 	temp.Execute(
@@ -191,7 +895,7 @@ func (a *Action) Print(c *Context) error {
 		map[string]interface{}{
 			"n": user.Name,
 			"e": user.Email,
-			"u": user.URL,
+			"u": user.Rule(),
 			"s": user.SigningKey,
 		},
 	)
@@ -0,0 +1,654 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+var (
+	acmeRemote   = &Remote{Name: "origin", URL: "git@github.com:acme/repo.git"}
+	otherRemote  = &Remote{Name: "origin", URL: "git@github.com:other/repo.git"}
+	errBadConfig = &GitError{Args: []string{"config"}, Stderr: "fatal: bad config line 1", Err: errors.New("exit status 128")}
+)
+
+func TestAction(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	homedir.DisableCache = true
+	defer func() { homedir.DisableCache = false }()
+	dir, _ := ioutil.TempDir(os.TempDir(), "git-user_test")
+	defer os.RemoveAll(dir)
+	hooks := filepath.Join(dir, "hooks")
+	shared := filepath.Join(dir, "shared.yaml")
+	ioutil.WriteFile(shared, []byte(`users:
+  - {URL: "git@gitlab.com:*", Name: "{{.Local.Name}}", Email: "{{.Local.Email}}"}
+`), 0644)
+
+	tests := []struct {
+		name     string
+		action   func(*Action, *Context) error
+		git      *fakeGit
+		setup    func(*Context, *fakeGit)
+		want     string
+		wantCode int
+		check    func(*testing.T, *Context, *fakeGit)
+	}{
+		{
+			name:     "show outside work tree",
+			action:   (*Action).ShowUser,
+			git:      newFakeGit(""),
+			want:     "outside work tree",
+			wantCode: ExitOutsideWorkTree,
+		},
+		{
+			name:   "show no remote",
+			action: (*Action).ShowUser,
+			git:    newFakeGit("/src/repo"),
+			want:   "no remote url",
+		},
+		{
+			name:   "show no match",
+			action: (*Action).ShowUser,
+			git:    newFakeGit("/src/repo", otherRemote),
+			want:   "no git-user config",
+		},
+		{
+			name:   "show no match strict",
+			action: (*Action).ShowUser,
+			git:    newFakeGit("/src/repo", otherRemote),
+			setup: func(c *Context, git *fakeGit) {
+				c.Option.Strict = true
+			},
+			want:     "no git-user config",
+			wantCode: ExitNoMatch,
+		},
+		{
+			name:   "show match",
+			action: (*Action).ShowUser,
+			git:    newFakeGit("/src/repo", acmeRemote),
+			want:   "Email: acme@example.com",
+		},
+		{
+			name:   "show read failure",
+			action: (*Action).ShowUser,
+			git:    newFakeGit("/src/repo", acmeRemote),
+			setup: func(c *Context, git *fakeGit) {
+				git.getErr = errBadConfig
+			},
+			want:     "bad config line 1",
+			wantCode: ExitGit,
+		},
+		{
+			name:     "explain outside work tree",
+			action:   (*Action).Explain,
+			git:      newFakeGit(""),
+			want:     "outside work tree",
+			wantCode: ExitOutsideWorkTree,
+		},
+		{
+			name:   "explain no remote",
+			action: (*Action).Explain,
+			git:    newFakeGit("/src/repo"),
+			want:   "no remote url",
+		},
+		{
+			name:   "explain no match",
+			action: (*Action).Explain,
+			git:    newFakeGit("/src/repo", otherRemote),
+			want:   "no git-user config",
+		},
+		{
+			name:   "explain match",
+			action: (*Action).Explain,
+			git:    newFakeGit("/src/repo", acmeRemote),
+			want:   "selected",
+		},
+		{
+			name:     "set outside work tree",
+			action:   (*Action).SetUser,
+			git:      newFakeGit(""),
+			setup:    setArgs("Mike", "mike@example.com"),
+			want:     "required `--url` option or inside work tree",
+			wantCode: ExitOutsideWorkTree,
+		},
+		{
+			name:     "set no remote",
+			action:   (*Action).SetUser,
+			git:      newFakeGit("/src/repo"),
+			setup:    setArgs("Mike", "mike@example.com"),
+			want:     "required `--url` option or set your remote url",
+			wantCode: ExitFailure,
+		},
+		{
+			name:   "set remote url",
+			action: (*Action).SetUser,
+			git:    newFakeGit("/src/repo", otherRemote),
+			setup:  setArgs("Mike", "mike@example.com"),
+			want:   "URL: git@github.com:other/repo.git",
+			check: func(t *testing.T, c *Context, git *fakeGit) {
+				data, _ := ioutil.ReadFile(c.Option.Config)
+				if !bytes.Contains(data, []byte("git@github.com:other/repo.git")) {
+					t.Errorf("SetUser() saved %s", data)
+				}
+			},
+		},
+		{
+			name:     "set without args",
+			action:   (*Action).SetUser,
+			git:      newFakeGit("/src/repo", otherRemote),
+			wantCode: ExitFailure,
+		},
+		{
+			name:   "set missing profile",
+			action: (*Action).SetUser,
+			git:    newFakeGit("/src/repo", otherRemote),
+			setup: func(c *Context, git *fakeGit) {
+				c.Option.Set.Profile = "missing"
+			},
+			wantCode: ExitFailure,
+		},
+		{
+			name:   "set save failure",
+			action: (*Action).SetUser,
+			git:    newFakeGit("/src/repo", otherRemote),
+			setup: func(c *Context, git *fakeGit) {
+				setArgs("Mike", "mike@example.com")(c, git)
+				c.Option.Config = filepath.Join(shared, "config.json")
+			},
+			wantCode: ExitConfig,
+		},
+		{
+			name:     "local outside work tree",
+			action:   (*Action).ShowLocalUser,
+			git:      newFakeGit(""),
+			want:     "outside work tree",
+			wantCode: ExitOutsideWorkTree,
+		},
+		{
+			name:   "local",
+			action: (*Action).ShowLocalUser,
+			git:    newFakeGit("/src/repo", acmeRemote),
+			setup: func(c *Context, git *fakeGit) {
+				git.local["user.name"] = "Local"
+			},
+			want: "URL: git@github.com:acme/repo.git  Name: Local",
+		},
+		{
+			name:   "local read failure",
+			action: (*Action).ShowLocalUser,
+			git:    newFakeGit("/src/repo", acmeRemote),
+			setup: func(c *Context, git *fakeGit) {
+				git.getErr = errBadConfig
+			},
+			want:     "bad config line 1",
+			wantCode: ExitGit,
+		},
+		{
+			name:     "sync outside work tree",
+			action:   (*Action).SyncGitUserToLocal,
+			git:      newFakeGit(""),
+			want:     "outside work tree",
+			wantCode: ExitOutsideWorkTree,
+		},
+		{
+			name:   "sync no remote",
+			action: (*Action).SyncGitUserToLocal,
+			git:    newFakeGit("/src/repo"),
+			want:   "no remote url",
+		},
+		{
+			name:   "sync no match",
+			action: (*Action).SyncGitUserToLocal,
+			git:    newFakeGit("/src/repo", otherRemote),
+			setup: func(c *Context, git *fakeGit) {
+				git.local["user.email"] = "acme@example.com"
+			},
+			want:  "- user.email = acme@example.com",
+			check: wantLocal(map[string]string{}),
+		},
+		{
+			name:   "sync no match strict",
+			action: (*Action).SyncGitUserToLocal,
+			git:    newFakeGit("/src/repo", otherRemote),
+			setup: func(c *Context, git *fakeGit) {
+				c.Option.Strict = true
+			},
+			wantCode: ExitNoMatch,
+		},
+		{
+			name:   "sync match",
+			action: (*Action).SyncGitUserToLocal,
+			git:    newFakeGit("/src/repo", acmeRemote),
+			want:   "+ user.email = acme@example.com",
+			check:  wantLocal(map[string]string{"user.name": "Acme", "user.email": "acme@example.com", "commit.gpgsign": "false", "tag.gpgsign": "false"}),
+		},
+		{
+			name:   "sync dry run",
+			action: (*Action).SyncGitUserToLocal,
+			git:    newFakeGit("/src/repo", acmeRemote),
+			setup: func(c *Context, git *fakeGit) {
+				c.Option.Sync.DryRun = true
+			},
+			want:  "+ user.email = acme@example.com",
+			check: wantLocal(map[string]string{}),
+		},
+		{
+			name:   "sync set failure",
+			action: (*Action).SyncGitUserToLocal,
+			git:    newFakeGit("/src/repo", acmeRemote),
+			setup: func(c *Context, git *fakeGit) {
+				git.setErr = errors.New("could not lock config file")
+			},
+			want:     "could not lock config file",
+			wantCode: ExitFailure,
+		},
+		{
+			name:   "sync locked",
+			action: (*Action).SyncGitUserToLocal,
+			git:    newFakeGit("/src/repo", acmeRemote),
+			setup: func(c *Context, git *fakeGit) {
+				git.setErr = &GitError{Args: []string{"config"}, Err: ErrLocked}
+			},
+			want:     "config file is locked",
+			wantCode: ExitGit,
+		},
+		{
+			name:   "sync no match without local config",
+			action: (*Action).SyncGitUserToLocal,
+			git:    newFakeGit("/src/repo", otherRemote),
+			want:   "",
+			check:  wantLocal(map[string]string{}),
+		},
+		{
+			name:   "print outside work tree",
+			action: (*Action).Print,
+			git:    newFakeGit(""),
+		},
+		{
+			name:   "print no match",
+			action: (*Action).Print,
+			git:    newFakeGit("/src/repo", otherRemote),
+		},
+		{
+			name:   "print outside work tree strict",
+			action: (*Action).Print,
+			git:    newFakeGit(""),
+			setup: func(c *Context, git *fakeGit) {
+				c.Option.Strict = true
+			},
+			want:     "outside work tree",
+			wantCode: ExitOutsideWorkTree,
+		},
+		{
+			name:   "print no match strict",
+			action: (*Action).Print,
+			git:    newFakeGit("/src/repo", otherRemote),
+			setup: func(c *Context, git *fakeGit) {
+				c.Option.Strict = true
+			},
+			wantCode: ExitNoMatch,
+		},
+		{
+			name:   "print match",
+			action: (*Action).Print,
+			git:    newFakeGit("/src/repo", acmeRemote),
+			want:   "[acme@example.com]",
+			check:  wantLocal(map[string]string{"user.name": "Acme", "user.email": "acme@example.com", "commit.gpgsign": "false", "tag.gpgsign": "false"}),
+		},
+		{
+			name:   "print without validating keys",
+			action: (*Action).Print,
+			git:    newFakeGit("/src/repo", acmeRemote),
+			setup: func(c *Context, git *fakeGit) {
+				c.Users[0].SSHKey = filepath.Join(dir, "missing_key")
+			},
+			want: "[acme@example.com]",
+			check: func(t *testing.T, c *Context, git *fakeGit) {
+				if got := git.local["core.sshcommand"]; !strings.Contains(got, "missing_key") {
+					t.Errorf("Print() core.sshCommand = %q", got)
+				}
+			},
+		},
+		{
+			name:   "hook check outside work tree",
+			action: (*Action).CheckHook,
+			git:    newFakeGit(""),
+		},
+		{
+			name:   "hook check outside work tree strict",
+			action: (*Action).CheckHook,
+			git:    newFakeGit(""),
+			setup: func(c *Context, git *fakeGit) {
+				c.Option.Strict = true
+			},
+			want:     "outside work tree",
+			wantCode: ExitOutsideWorkTree,
+		},
+		{
+			name:   "hook check no match",
+			action: (*Action).CheckHook,
+			git:    newFakeGit("/src/repo", otherRemote),
+		},
+		{
+			name:   "hook check no match strict",
+			action: (*Action).CheckHook,
+			git:    newFakeGit("/src/repo", otherRemote),
+			setup: func(c *Context, git *fakeGit) {
+				c.Option.Strict = true
+			},
+			want:     "no git-user config matched",
+			wantCode: ExitNoMatch,
+		},
+		{
+			name:   "hook check global user",
+			action: (*Action).CheckHook,
+			git:    newFakeGit("/src/repo", acmeRemote),
+			setup: func(c *Context, git *fakeGit) {
+				git.global["user.name"] = "Acme"
+				git.global["user.email"] = "acme@example.com"
+			},
+		},
+		{
+			name:     "hook check reject",
+			action:   (*Action).CheckHook,
+			git:      newFakeGit("/src/repo", acmeRemote),
+			want:     `user.email is "", want "acme@example.com"`,
+			wantCode: ExitFailure,
+			check:    wantLocal(map[string]string{}),
+		},
+		{
+			name:   "hook check global signing key",
+			action: (*Action).CheckHook,
+			git:    newFakeGit("/src/repo", acmeRemote),
+			setup: func(c *Context, git *fakeGit) {
+				git.global["user.name"] = "Acme"
+				git.global["user.email"] = "acme@example.com"
+				git.global["user.signingkey"] = "PERSONAL"
+			},
+		},
+		{
+			name:   "hook check stale signing key",
+			action: (*Action).CheckHook,
+			git:    newFakeGit("/src/repo", acmeRemote),
+			setup: func(c *Context, git *fakeGit) {
+				git.global["user.name"] = "Acme"
+				git.global["user.email"] = "acme@example.com"
+				git.global["user.signingkey"] = "PERSONAL"
+				git.local["user.signingkey"] = "OTHER"
+			},
+			want:     `user.signingkey is "OTHER", want unset`,
+			wantCode: ExitFailure,
+		},
+		{
+			name:   "hook check read failure",
+			action: (*Action).CheckHook,
+			git:    newFakeGit("/src/repo", acmeRemote),
+			setup: func(c *Context, git *fakeGit) {
+				git.remotes = nil
+				git.getErr = errBadConfig
+			},
+			want:     "bad config line 1",
+			wantCode: ExitGit,
+		},
+		{
+			name:   "hook check fix",
+			action: (*Action).CheckHook,
+			git:    newFakeGit("/src/repo", acmeRemote),
+			setup: func(c *Context, git *fakeGit) {
+				c.Option.Hook.Check.Fix = true
+			},
+			want:     "git-user fixed local git user",
+			wantCode: ExitFailure,
+			check:    wantLocal(map[string]string{"user.name": "Acme", "user.email": "acme@example.com", "commit.gpgsign": "false", "tag.gpgsign": "false"}),
+		},
+		{
+			name:     "hook install outside work tree",
+			action:   (*Action).InstallHook,
+			git:      newFakeGit(""),
+			want:     "required `--global` option or inside work tree",
+			wantCode: ExitOutsideWorkTree,
+		},
+		{
+			name:   "hook install",
+			action: (*Action).InstallHook,
+			git:    &fakeGit{workTree: "/src/repo", gitPath: dir},
+			want:   "install " + filepath.Join(hooks, "pre-commit"),
+		},
+		{
+			name:   "hook install global",
+			action: (*Action).InstallHook,
+			git:    newFakeGit(""),
+			setup: func(c *Context, git *fakeGit) {
+				c.Option.Hook.Install.Global = true
+				c.Option.Hook.Install.PrePush = true
+				git.global["core.hookspath"] = hooks
+			},
+			want: "install " + filepath.Join(hooks, "pre-push"),
+		},
+		{
+			name:   "hook install global failure",
+			action: (*Action).InstallHook,
+			git:    newFakeGit(""),
+			setup: func(c *Context, git *fakeGit) {
+				c.Option.Hook.Install.Global = true
+				git.setErr = errors.New("could not lock config file")
+			},
+			wantCode: ExitFailure,
+		},
+		{
+			name:     "audit outside work tree",
+			action:   (*Action).Audit,
+			git:      newFakeGit(""),
+			want:     "outside work tree",
+			wantCode: ExitOutsideWorkTree,
+		},
+		{
+			name:   "audit no match",
+			action: (*Action).Audit,
+			git:    newFakeGit("/src/repo", otherRemote),
+			want:   "no git-user config",
+		},
+		{
+			name:   "audit match",
+			action: (*Action).Audit,
+			git:    newFakeGit("/src/repo", acmeRemote),
+			setup: func(c *Context, git *fakeGit) {
+				git.commits = auditCommits()[:1]
+			},
+			want: "0 of 1 commits do not match",
+		},
+		{
+			name:   "audit wrong commits",
+			action: (*Action).Audit,
+			git:    newFakeGit("/src/repo", acmeRemote),
+			setup: func(c *Context, git *fakeGit) {
+				git.commits = auditCommits()
+			},
+			want:     "2 of 3 commits do not match",
+			wantCode: ExitFailure,
+		},
+		{
+			name:   "audit commits failure",
+			action: (*Action).Audit,
+			git:    newFakeGit("/src/repo", acmeRemote),
+			setup: func(c *Context, git *fakeGit) {
+				git.commitsErr = errors.New("bad revision")
+			},
+			wantCode: ExitFailure,
+		},
+		{
+			name:   "audit fix pushed",
+			action: (*Action).Audit,
+			git:    newFakeGit("/src/repo", acmeRemote),
+			setup: func(c *Context, git *fakeGit) {
+				c.Option.Audit.Fix = true
+				git.commits = auditCommits()
+				git.pushed["c3"] = true
+			},
+			want:     "c3 is already pushed",
+			wantCode: ExitFailure,
+			check: func(t *testing.T, c *Context, git *fakeGit) {
+				if git.rewritten != nil {
+					t.Errorf("Audit() rewrote pushed commits")
+				}
+			},
+		},
+		{
+			name:   "audit fix",
+			action: (*Action).Audit,
+			git:    newFakeGit("/src/repo", acmeRemote),
+			setup: func(c *Context, git *fakeGit) {
+				c.Option.Audit.Fix = true
+				git.commits = auditCommits()
+			},
+			want: "rewrite 2 commits with Acme <acme@example.com>",
+			check: func(t *testing.T, c *Context, git *fakeGit) {
+				if want := []string{"c2^", "Acme", "acme@example.com"}; !reflect.DeepEqual(git.rewritten, want) {
+					t.Errorf("Audit() rewritten = %v, want %v", git.rewritten, want)
+				}
+			},
+		},
+		{
+			name:   "import with global user",
+			action: (*Action).Import,
+			git:    newFakeGit(""),
+			setup: func(c *Context, git *fakeGit) {
+				c.Option.Import.Args.Path = shared
+				c.Option.Import.Mode = ImportMerge
+				git.global["user.name"] = "Mike"
+				git.global["user.email"] = "mike@example.com"
+			},
+			want: "git@gitlab.com:*",
+			check: func(t *testing.T, c *Context, git *fakeGit) {
+				if user := c.Users[len(c.Users)-1]; user.Email != "mike@example.com" {
+					t.Errorf("Import() user = %+v", user)
+				}
+			},
+		},
+		{
+			name:   "export includeif locked",
+			action: (*Action).ExportIncludeIf,
+			git:    newFakeGit(""),
+			setup: func(c *Context, git *fakeGit) {
+				home := filepath.Join(dir, "locked")
+				os.RemoveAll(home)
+				os.MkdirAll(home, 0755)
+				ioutil.WriteFile(filepath.Join(home, ".gitconfig"), []byte("[alias]\n\tst = status\n"), 0644)
+				ioutil.WriteFile(filepath.Join(home, ".gitconfig.lock"), nil, 0644)
+				c.Option.ExportIncludeIf.Dir = home
+				c.Option.ExportIncludeIf.GitConfig = filepath.Join(home, ".gitconfig")
+			},
+			want:     "config file is locked",
+			wantCode: ExitGit,
+			check: func(t *testing.T, c *Context, git *fakeGit) {
+				if data, _ := ioutil.ReadFile(filepath.Join(dir, "locked", ".gitconfig")); string(data) != "[alias]\n\tst = status\n" {
+					t.Errorf("ExportIncludeIf() wrote locked .gitconfig = %s", data)
+				}
+			},
+		},
+		{
+			name:   "export includeif into home",
+			action: (*Action).ExportIncludeIf,
+			git:    newFakeGit(""),
+			setup: func(c *Context, git *fakeGit) {
+				home := filepath.Join(dir, "home")
+				os.RemoveAll(home)
+				os.MkdirAll(home, 0755)
+				ioutil.WriteFile(filepath.Join(home, ".gitconfig"), []byte("[alias]\n\tst = status\n"), 0644)
+				ioutil.WriteFile(filepath.Join(home, "other.gitconfig"), []byte("[core]\n"), 0644)
+				ioutil.WriteFile(filepath.Join(home, "stale.gitconfig"), []byte(identityFileHeader+"\n[user]\n"), 0644)
+				c.Option.ExportIncludeIf.Dir = home
+				c.Option.ExportIncludeIf.GitConfig = filepath.Join(home, ".gitconfig")
+			},
+			want: "update " + filepath.Join(dir, "home", ".gitconfig"),
+			check: func(t *testing.T, c *Context, git *fakeGit) {
+				home := filepath.Join(dir, "home")
+				if data, _ := ioutil.ReadFile(filepath.Join(home, ".gitconfig")); !strings.HasPrefix(string(data), "[alias]\n\tst = status\n"+includeIfBegin) {
+					t.Errorf("ExportIncludeIf() .gitconfig = %s", data)
+				}
+				if _, err := os.Stat(filepath.Join(home, ".gitconfig.lock")); !os.IsNotExist(err) {
+					t.Errorf("ExportIncludeIf() left .gitconfig.lock")
+				}
+				if _, err := os.Stat(filepath.Join(home, "other.gitconfig")); err != nil {
+					t.Errorf("ExportIncludeIf() removed other.gitconfig: %v", err)
+				}
+				if _, err := os.Stat(filepath.Join(home, "stale.gitconfig")); !os.IsNotExist(err) {
+					t.Errorf("ExportIncludeIf() kept stale.gitconfig")
+				}
+				for _, user := range c.Users {
+					if _, err := user.IncludeIfConditions(); err != nil {
+						continue
+					}
+					file := filepath.Join(home, IdentityFileName(user))
+					if data, _ := ioutil.ReadFile(file); !strings.HasPrefix(string(data), identityFileHeader+"\n") {
+						t.Errorf("ExportIncludeIf() %s = %s", file, data)
+					}
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.RemoveAll(hooks)
+			c := NewContext()
+			c.Option.Config = filepath.Join(dir, "config.json")
+			c.Option.Print.Format = "[{e}]"
+			c.Profiles = Profiles{&Profile{Profile: "acme", Identity: Identity{Name: "Acme", Email: "acme@example.com"}}}
+			c.Users = Users{&User{URL: "git@github.com:acme/*", Profile: "acme", Name: "Acme", Email: "acme@example.com"}}
+			c.NewGit = func(string) GitBackend { return tt.git }
+			if tt.setup != nil {
+				tt.setup(c, tt.git)
+			}
+
+			out := &bytes.Buffer{}
+			err := tt.action(&Action{printer: NewPrinter(PrintDefault, out)}, c)
+			if got := exitStatus(err); got != tt.wantCode {
+				t.Fatalf("error = %v, exit status %d, want %d, output %s", err, got, tt.wantCode, out)
+			}
+			if got := out.String() + errString(err); !strings.Contains(got, tt.want) || tt.want == "" && out.Len() > 0 {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+			if tt.check != nil {
+				tt.check(t, c, tt.git)
+			}
+		})
+	}
+}
+
+func setArgs(name, email string) func(*Context, *fakeGit) {
+	return func(c *Context, git *fakeGit) {
+		c.Option.Set.Args = SetArgs{Name: name, Email: email}
+	}
+}
+
+func wantLocal(want map[string]string) func(*testing.T, *Context, *fakeGit) {
+	return func(t *testing.T, c *Context, git *fakeGit) {
+		if !reflect.DeepEqual(git.local, want) {
+			t.Errorf("local config = %v, want %v", git.local, want)
+		}
+	}
+}
+
+func auditCommits() []*Commit {
+	return []*Commit{
+		{Hash: "c1", AuthorEmail: "acme@example.com", CommitterEmail: "acme@example.com"},
+		{Hash: "c2", AuthorEmail: "mike@example.com", CommitterEmail: "acme@example.com"},
+		{Hash: "c3", AuthorEmail: "acme@example.com", CommitterEmail: "mike@example.com"},
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
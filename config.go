@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// config file format version. version 1 is flat users array
+const (
+	legacyConfigVersion = 1
+	configVersion       = 2
+)
+
+// config file locations
+const (
+	legacyConfigBase = "~/git-user"
+	configDirName    = "git-user"
+	configFileName   = "config"
+	dropInDirName    = "conf.d"
+)
+
+// extensions of config file, tried in order
+var (
+	configExts       = []string{"", ".json", ".yaml", ".yml", ".toml"}
+	legacyConfigExts = []string{".json", ".yaml", ".yml", ".toml"}
+)
+
+// xdgConfigDir $XDG_CONFIG_HOME/git-user. XDG_CONFIG_HOME is ~/.config if not set or not absolute
+func xdgConfigDir() (string, error) {
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if !filepath.IsAbs(base) {
+		home, err := homedir.Dir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".config")
+	}
+	return filepath.Join(base, configDirName), nil
+}
+
+// existingConfig first existing config file of base with extensions
+func existingConfig(base string, exts []string) (string, bool) {
+	for _, ext := range exts {
+		if info, err := os.Stat(base + ext); err == nil && !info.IsDir() {
+			return base + ext, true
+		}
+	}
+	return "", false
+}
+
+// dropInConfigs config files in directory sorted by name. hidden files and unknown extensions are ignored
+func dropInConfigs(dir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, info := range infos {
+		ext := strings.ToLower(filepath.Ext(info.Name()))
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+		if ext == ".json" || ext == ".yaml" || ext == ".yml" || ext == ".toml" {
+			paths = append(paths, filepath.Join(dir, info.Name()))
+		}
+	}
+	return paths, nil
+}
+
+// Settings global settings of config file
+type Settings struct {
+	Remotes []string `json:"remotes,omitempty"`
+}
+
+// document is config file
+type document struct {
+	Version  int      `json:"version"`
+	Profiles Profiles `json:"profiles"`
+	Users    Users    `json:"users"`
+	Settings Settings `json:"settings"`
+}
+
+// decodeConfig decode config file of format and any supported version
+func decodeConfig(data []byte, format string) (*document, error) {
+	data, err := toJSON(data, format)
+	if err != nil {
+		return nil, err
+	}
+	doc := &document{}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		doc.Version = legacyConfigVersion
+		return doc, json.Unmarshal(data, &doc.Users)
+	}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, err
+	}
+	if doc.Version == 0 {
+		// profiles document before versioning
+		doc.Version = configVersion
+	}
+	if doc.Version > configVersion {
+		return nil, fmt.Errorf("config version %d is newer than supported version %d. upgrade git-user", doc.Version, configVersion)
+	}
+	return doc, nil
+}
+
+// encodeConfig encode config file of format and current version. json is indented, comments of previous yaml are kept
+func encodeConfig(doc *document, format string, previous []byte) ([]byte, error) {
+	doc.Version = configVersion
+	if doc.Profiles == nil {
+		doc.Profiles = Profiles{}
+	}
+	if doc.Users == nil {
+		doc.Users = Users{}
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return fromJSON(append(data, '\n'), format, previous)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+func Test_decodeConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		wantVersion int
+		wantUsers   int
+		wantErr     bool
+	}{
+		{"legacy", `[{"URL":"git@github.com:*","Name":"Mike","Email":"mike@example.com","SigningKey":""}]`, legacyConfigVersion, 1, false},
+		{"legacy with space", " \n[]", legacyConfigVersion, 0, false},
+		{"current", `{"version":2,"profiles":[{"Profile":"work"}],"users":[{"URL":"git@github.com:*","Profile":"work"}]}`, configVersion, 1, false},
+		{"without version", `{"profiles":[],"users":[]}`, configVersion, 0, false},
+		{"newer version", `{"version":3,"users":[]}`, 0, 0, true},
+		{"broken", `{"version":`, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeConfig([]byte(tt.data), FormatJSON)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Version != tt.wantVersion || len(got.Users) != tt.wantUsers {
+				t.Errorf("decodeConfig() version = %v, users = %v, want %v, %v", got.Version, len(got.Users), tt.wantVersion, tt.wantUsers)
+			}
+		})
+	}
+}
+
+func Test_encodeConfig(t *testing.T) {
+	doc := &document{
+		Profiles: Profiles{&Profile{Profile: "work", Identity: Identity{Name: "Mike", Email: "mike@acme.com"}}},
+		Users:    Users{&User{URL: "git@github.com:acme/*", Profile: "work"}},
+		Settings: Settings{Remotes: []string{"github", "origin"}},
+	}
+	data, err := encodeConfig(doc, FormatJSON, nil)
+	if err != nil {
+		t.Fatalf("encodeConfig() error = %v", err)
+	}
+	got, err := decodeConfig(data, FormatJSON)
+	if err != nil {
+		t.Fatalf("decodeConfig() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, doc) {
+		t.Errorf("decodeConfig() = %+v, want %+v", got, doc)
+	}
+
+	data, _ = encodeConfig(&document{}, FormatJSON, nil)
+	if string(data) != "{\n  \"version\": 2,\n  \"profiles\": [],\n  \"users\": [],\n  \"settings\": {}\n}\n" {
+		t.Errorf("encodeConfig() = %s", data)
+	}
+}
+
+func Test_xdgConfigDir(t *testing.T) {
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	home, _ := homedir.Dir()
+	tests := []struct {
+		name string
+		env  string
+		want string
+	}{
+		{"absolute", "/etc/xdg", "/etc/xdg/git-user"},
+		{"not set", "", filepath.Join(home, ".config", "git-user")},
+		{"relative", "config", filepath.Join(home, ".config", "git-user")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("XDG_CONFIG_HOME", tt.env)
+			if got, _ := xdgConfigDir(); got != tt.want {
+				t.Errorf("xdgConfigDir() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_dropInConfigs(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "git-user_test")
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"20-personal.toml", "10-company.yaml", ".hidden.json", "README.md", "30-team.json"} {
+		ioutil.WriteFile(filepath.Join(dir, name), nil, 0644)
+	}
+	os.Mkdir(filepath.Join(dir, "40-dir.json"), 0755)
+
+	got, err := dropInConfigs(dir)
+	if err != nil {
+		t.Fatalf("dropInConfigs() error = %v", err)
+	}
+	want := []string{
+		filepath.Join(dir, "10-company.yaml"),
+		filepath.Join(dir, "20-personal.toml"),
+		filepath.Join(dir, "30-team.json"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dropInConfigs() = %v, want %v", got, want)
+	}
+	if got, err := dropInConfigs(filepath.Join(dir, "missing")); got != nil || err != nil {
+		t.Errorf("dropInConfigs() = %v, %v, want nil", got, err)
+	}
+}
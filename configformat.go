@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// config file format
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// configFormat format of config file by extension. json if unknown
+func configFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	}
+	return FormatJSON
+}
+
+// toJSON convert config file of format into json
+func toJSON(data []byte, format string) ([]byte, error) {
+	switch format {
+	case FormatYAML:
+		var node yaml.Node
+		if err := yaml.Unmarshal(data, &node); err != nil {
+			return nil, err
+		}
+		buf := &bytes.Buffer{}
+		if err := writeYAMLNodeJSON(buf, &node); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case FormatTOML:
+		// toml table is decoded into map, so keys are sorted
+		v := map[string]interface{}{}
+		if _, err := toml.Decode(string(data), &v); err != nil {
+			return nil, err
+		}
+		return json.Marshal(v)
+	}
+	return data, nil
+}
+
+// fromJSON convert json into config file of format. comments of previous yaml are kept
+func fromJSON(data []byte, format string, previous []byte) ([]byte, error) {
+	switch format {
+	case FormatYAML:
+		var node yaml.Node
+		if err := yaml.Unmarshal(data, &node); err != nil {
+			return nil, err
+		}
+		clearYAMLStyle(&node)
+		var old yaml.Node
+		if yaml.Unmarshal(previous, &old) == nil {
+			copyYAMLComments(&old, &node)
+		}
+		buf := &bytes.Buffer{}
+		encoder := yaml.NewEncoder(buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(&node); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), encoder.Close()
+	case FormatTOML:
+		v, err := decodeOrdered(json.NewDecoder(bytes.NewReader(data)))
+		if err != nil {
+			return nil, err
+		}
+		object, ok := v.(orderedObject)
+		if !ok {
+			return nil, fmt.Errorf("toml config must be table")
+		}
+		buf := &bytes.Buffer{}
+		writeTOMLTable(buf, nil, object)
+		return buf.Bytes(), nil
+	}
+	return data, nil
+}
+
+// writeYAMLNodeJSON write yaml node as json keeping order of mapping keys
+func writeYAMLNodeJSON(w io.Writer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			_, err := io.WriteString(w, "null")
+			return err
+		}
+		return writeYAMLNodeJSON(w, node.Content[0])
+	case yaml.AliasNode:
+		return writeYAMLNodeJSON(w, node.Alias)
+	case yaml.MappingNode, yaml.SequenceNode:
+		open, close := "[", "]"
+		step := 1
+		if node.Kind == yaml.MappingNode {
+			open, close = "{", "}"
+			step = 2
+		}
+		io.WriteString(w, open)
+		for i := 0; i+step-1 < len(node.Content); i += step {
+			if i > 0 {
+				io.WriteString(w, ",")
+			}
+			if step == 2 {
+				key, _ := json.Marshal(node.Content[i].Value)
+				w.Write(key)
+				io.WriteString(w, ":")
+			}
+			if err := writeYAMLNodeJSON(w, node.Content[i+step-1]); err != nil {
+				return err
+			}
+		}
+		_, err := io.WriteString(w, close)
+		return err
+	}
+
+	var v interface{}
+	if err := node.Decode(&v); err != nil {
+		return err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("line %d: %v", node.Line, err)
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// clearYAMLStyle use block style and plain scalar where possible
+func clearYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearYAMLStyle(child)
+	}
+}
+
+// copyYAMLComments copy comments of same mapping key, or of sequence item with same first key and value
+func copyYAMLComments(old, node *yaml.Node) {
+	if old.Kind != node.Kind {
+		return
+	}
+	node.HeadComment, node.LineComment, node.FootComment = old.HeadComment, old.LineComment, old.FootComment
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(old.Content) > 0 && len(node.Content) > 0 {
+			copyYAMLComments(old.Content[0], node.Content[0])
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			for j := 0; j+1 < len(old.Content); j += 2 {
+				if old.Content[j].Value == node.Content[i].Value {
+					copyYAMLComments(old.Content[j], node.Content[i])
+					copyYAMLComments(old.Content[j+1], node.Content[i+1])
+					break
+				}
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			for j, oldItem := range old.Content {
+				if yamlItemKey(oldItem, j) == yamlItemKey(item, i) {
+					copyYAMLComments(oldItem, item)
+					break
+				}
+			}
+		}
+	}
+}
+
+// yamlItemKey identify sequence item by first key and value of mapping, or by index
+func yamlItemKey(node *yaml.Node, index int) string {
+	if node.Kind == yaml.MappingNode && len(node.Content) >= 2 {
+		return node.Content[0].Value + "\x00" + node.Content[1].Value
+	}
+	return fmt.Sprintf("\x00%d", index)
+}
+
+// orderedObject json object keeping order of keys
+type orderedObject []orderedMember
+
+type orderedMember struct {
+	key   string
+	value interface{}
+}
+
+// decodeOrdered decode json value. object is orderedObject, number is json.Number
+func decodeOrdered(decoder *json.Decoder) (interface{}, error) {
+	decoder.UseNumber()
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return token, nil
+	}
+	switch delim {
+	case '{':
+		object := orderedObject{}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrdered(decoder)
+			if err != nil {
+				return nil, err
+			}
+			object = append(object, orderedMember{key: key.(string), value: value})
+		}
+		_, err = decoder.Token()
+		return object, err
+	case '[':
+		array := []interface{}{}
+		for decoder.More() {
+			value, err := decodeOrdered(decoder)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		_, err = decoder.Token()
+		return array, err
+	}
+	return nil, fmt.Errorf("unexpected %v", delim)
+}
+
+var tomlBareKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// writeTOMLTable write key values, then sub tables and arrays of tables. null is omitted
+func writeTOMLTable(w io.Writer, path []string, object orderedObject) {
+	var tables []orderedMember
+	for _, member := range object {
+		switch value := member.value.(type) {
+		case nil:
+		case orderedObject:
+			tables = append(tables, member)
+		case []interface{}:
+			if len(value) > 0 {
+				if _, ok := value[0].(orderedObject); ok {
+					tables = append(tables, member)
+					continue
+				}
+			}
+			fmt.Fprintf(w, "%s = %s\n", tomlKey(member.key), tomlValue(value))
+		default:
+			fmt.Fprintf(w, "%s = %s\n", tomlKey(member.key), tomlValue(value))
+		}
+	}
+	for _, member := range tables {
+		sub := append(append([]string{}, path...), tomlKey(member.key))
+		if object, ok := member.value.(orderedObject); ok {
+			fmt.Fprintf(w, "\n[%s]\n", strings.Join(sub, "."))
+			writeTOMLTable(w, sub, object)
+			continue
+		}
+		for _, item := range member.value.([]interface{}) {
+			fmt.Fprintf(w, "\n[[%s]]\n", strings.Join(sub, "."))
+			if object, ok := item.(orderedObject); ok {
+				writeTOMLTable(w, sub, object)
+			}
+		}
+	}
+}
+
+func tomlKey(key string) string {
+	if tomlBareKeyPattern.MatchString(key) {
+		return key
+	}
+	return tomlValue(key)
+}
+
+func tomlValue(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		buf := &bytes.Buffer{}
+		encoder := json.NewEncoder(buf)
+		encoder.SetEscapeHTML(false)
+		encoder.Encode(value)
+		return strings.TrimSpace(buf.String())
+	case []interface{}:
+		values := make([]string, len(value))
+		for i, item := range value {
+			values[i] = tomlValue(item)
+		}
+		return "[" + strings.Join(values, ", ") + "]"
+	}
+	return fmt.Sprint(v)
+}
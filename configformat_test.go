@@ -0,0 +1,84 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_configFormat(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"~/git-user.json", FormatJSON},
+		{"~/git-user.yaml", FormatYAML},
+		{"~/git-user.YML", FormatYAML},
+		{"~/git-user.toml", FormatTOML},
+		{"~/git-user", FormatJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := configFormat(tt.path); got != tt.want {
+				t.Errorf("configFormat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_roundTrip(t *testing.T) {
+	doc := &document{
+		Profiles: Profiles{
+			&Profile{Profile: "work", Identity: Identity{Name: "Mike", Email: "true", GPGSign: true, SigningKey: "0123",
+				Config: ConfigMap{{Key: "http.proxy", Value: "http://proxy:8080"}, {Key: "core.sshCommand", Value: "ssh -i \"~/.ssh/work\""}}}},
+		},
+		Users:    Users{&User{URL: "git@github.com:acme/*", Profile: "work", Priority: 10}},
+		Settings: Settings{Remotes: []string{"github", "origin"}},
+	}
+	for _, format := range []string{FormatJSON, FormatYAML, FormatTOML} {
+		t.Run(format, func(t *testing.T) {
+			data, err := encodeConfig(doc, format, nil)
+			if err != nil {
+				t.Fatalf("encodeConfig() error = %v", err)
+			}
+			got, err := decodeConfig(data, format)
+			if err != nil {
+				t.Fatalf("decodeConfig() error = %v\n%s", err, data)
+			}
+			if format == FormatTOML {
+				// toml table is decoded into map, so keys are sorted
+				got.Profiles[0].Config[0], got.Profiles[0].Config[1] = got.Profiles[0].Config[1], got.Profiles[0].Config[0]
+			}
+			if !reflect.DeepEqual(got, doc) {
+				t.Errorf("decodeConfig() = %+v, want %+v\n%s", got, doc, data)
+			}
+		})
+	}
+}
+
+func Test_encodeConfig_yamlComments(t *testing.T) {
+	previous := `# identities
+version: 2
+profiles:
+  # day job
+  - Profile: work
+    Name: Mike
+    Email: mike@acme.com # old email
+users: []
+`
+	doc := &document{
+		Profiles: Profiles{
+			&Profile{Profile: "oss", Identity: Identity{Name: "Mike", Email: "mike@oss.org"}},
+			&Profile{Profile: "work", Identity: Identity{Name: "Mike", Email: "mike@new.acme.com"}},
+		},
+	}
+	data, err := encodeConfig(doc, FormatYAML, []byte(previous))
+	if err != nil {
+		t.Fatalf("encodeConfig() error = %v", err)
+	}
+	for _, comment := range []string{"# identities\n", "  # day job\n  - Profile: work\n", "Email: mike@new.acme.com # old email\n"} {
+		if !strings.Contains(string(data), comment) {
+			t.Errorf("encodeConfig() lost comment %q\n%s", comment, data)
+		}
+	}
+}
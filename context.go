@@ -1,17 +1,44 @@
 package main
 
 import (
-	"encoding/json"
-	"io"
+	"bytes"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 
 	"github.com/mitchellh/go-homedir"
 )
 
+// default remote priority
+var defaultRemotes = []string{"upstream", "origin"}
+
+// default global core.hooksPath
+const defaultGlobalHooksPath = "~/.git-user/hooks"
+
 type Context struct {
-	Option Option
-	Users  Users
+	Option   Option
+	Users    Users
+	Profiles Profiles
+	Settings Settings
+	// Files loaded config files in order. drop-in files of conf.d are read only
+	Files []string
+	// NewGit git backend of directory, selected by --git-backend if nil
+	NewGit func(dir string) GitBackend
+
+	shared   map[interface{}]string
+	settings Settings
+	lock     *fileLock
+}
+
+// commands modifying config file, which is locked during load-modify-save cycle
+var configCommands = map[string]bool{
+	"set":          true,
+	"delete":       true,
+	"import":       true,
+	"profile add":  true,
+	"profile edit": true,
+	"profile rm":   true,
 }
 
 type nullIO struct{}
@@ -32,85 +59,257 @@ func NewContext() *Context {
 			Delete: DeleteOption{
 				Args: DeleteArgs{},
 			},
-			Local: LocalOption{},
-			List:  ListOption{},
-			Sync:  SyncOption{},
-			Print: PrintOption{},
+			Local:   LocalOption{},
+			List:    ListOption{},
+			Sync:    SyncOption{},
+			Print:   PrintOption{},
+			Explain: ExplainOption{},
+
+			ExportIncludeIf: ExportIncludeIfOption{},
+			Hook: HookOption{
+				Install: HookInstallOption{},
+				Check:   HookCheckOption{},
+			},
+			Audit:  AuditOption{},
+			Doctor: DoctorOption{},
+			Profile: ProfileOption{
+				Add:    ProfileAddOption{Args: ProfileArgs{}},
+				Edit:   ProfileEditOption{Args: ProfileArgs{}},
+				Remove: ProfileRemoveOption{Args: ProfileRemoveArgs{}},
+				List:   ProfileListOption{},
+			},
+			ConfigCommand: ConfigOption{
+				Path: ConfigPathOption{},
+			},
+			Import: ImportOption{Args: ImportArgs{}},
+			Export: ExportOption{},
 		},
 	}
 }
 
-// LoadConfig load config from json
+// LoadConfig load drop-in files of conf.d and config file, later file overrides profiles and rules of same name.
+// config file of older version is migrated under lock, and the original is kept as .v<version>.bak
 func (c *Context) LoadConfig() error {
+	doc, migrated, err := c.loadConfig()
+	if err != nil || doc == nil || !migrated && doc.Version == configVersion {
+		return err
+	}
+	if c.lock == nil {
+		// e.g. command not modifying config. reload under lock, config may be migrated by another process
+		if err := c.Lock(); err != nil {
+			return err
+		}
+		defer c.Unlock()
+		c.Users, c.Profiles, c.Settings, c.Files = nil, nil, Settings{}, nil
+		if doc, migrated, err = c.loadConfig(); err != nil || doc == nil || !migrated && doc.Version == configVersion {
+			return err
+		}
+	}
+	suffix := backupSuffix
+	if doc.Version < configVersion {
+		suffix = fmt.Sprintf(".v%d%s", doc.Version, backupSuffix)
+	}
+	return configError(c.saveConfig(suffix))
+}
+
+// loadConfig load config files. doc is nil if config file does not exist, and migrated if identities are moved into profiles
+func (c *Context) loadConfig() (*document, bool, error) {
+	files, err := c.configFiles()
+	if err != nil {
+		return nil, false, err
+	}
+	path := files[len(files)-1]
+	c.shared = map[interface{}]string{}
+
+	var doc *document
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		d, err := decodeConfig(b, configFormat(file))
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: %v", file, err)
+		}
+		c.Files = append(c.Files, file)
+		if file == path {
+			doc = d
+			c.settings = d.Settings
+		} else {
+			for _, p := range d.Profiles {
+				c.shared[p] = file
+			}
+			for _, u := range d.Users {
+				c.shared[u] = file
+			}
+		}
+		for _, p := range d.Profiles {
+			c.Profiles.Put(p)
+		}
+		for _, u := range d.Users {
+			c.Users.Put(u)
+		}
+		if len(d.Settings.Remotes) > 0 {
+			c.Settings.Remotes = d.Settings.Remotes
+		}
+	}
+
+	var own Users
+	for _, u := range c.Users {
+		if _, ok := c.shared[u]; !ok {
+			own = append(own, u)
+		} else if u.Profile != "" {
+			// rules of drop-in file may have identity without profile
+			if err := c.Profiles.resolve(u); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+	migrated, err := c.Profiles.Resolve(own)
+	return doc, migrated, err
+}
+
+// SaveConfig save config file atomically, previous one is kept as .bak.
+// identity of users is saved in profiles, and drop-in files are not written
+func (c *Context) SaveConfig() error {
+	return configError(c.saveConfig(backupSuffix))
+}
+
+// saveConfig save config file, previous one is kept with suffix
+func (c *Context) saveConfig(suffix string) error {
+	if err := c.Users.Valid(); err != nil {
+		return err
+	}
+	if err := c.Profiles.Valid(); err != nil {
+		return err
+	}
 	path, err := c.configPath()
 	if err != nil {
 		return err
 	}
-	if _, statErr := os.Stat(path); os.IsNotExist(statErr) {
+	path = resolveSymlink(path)
+	doc := &document{Settings: c.settings}
+	for _, profile := range c.Profiles {
+		if _, ok := c.shared[profile]; !ok {
+			doc.Profiles = append(doc.Profiles, profile)
+		}
+	}
+	for _, user := range c.Users {
+		if _, ok := c.shared[user]; ok {
+			continue
+		}
+		if c.Profiles.Get(user.Profile) == nil {
+			return fmt.Errorf("profile %s of rule %s is not found", user.Profile, user.Rule())
+		}
+		doc.Users = append(doc.Users, user.rule())
+	}
+	previous, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	data, err := encodeConfig(doc, configFormat(path), previous)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(data, previous) {
 		return nil
-	} else {
-		bytes, err := ioutil.ReadFile(path)
-		if err != nil {
+	}
+
+	if c.lock == nil {
+		// e.g. saved without Lock
+		if err := c.Lock(); err != nil {
 			return err
 		}
-		return json.Unmarshal(bytes, &c.Users)
+		defer c.Unlock()
 	}
+	return writeFileWithBackup(path, suffix, data, previous, 0644)
 }
 
-// SaveConfig save config to json
-func (c *Context) SaveConfig() error {
+// Lock lock config file until Unlock. other git-user processes modifying config wait for it.
+// lock file is next to config file, symlink of which is resolved
+func (c *Context) Lock() error {
 	path, err := c.configPath()
 	if err != nil {
 		return err
 	}
-	bytes, err := json.Marshal(&c.Users)
+	lock, err := lockFile(resolveSymlink(path) + lockSuffix)
 	if err != nil {
 		return err
 	}
-	return ioutil.WriteFile(path, bytes, 0644)
+	c.lock = lock
+	return nil
+}
+
+// Unlock unlock config file
+func (c *Context) Unlock() error {
+	if c.lock == nil {
+		return nil
+	}
+	err := c.lock.Unlock()
+	c.lock = nil
+	return err
+}
+
+// sharedFile drop-in file defining user or profile
+func (c *Context) sharedFile(v interface{}) (string, bool) {
+	file, ok := c.shared[v]
+	return file, ok
 }
 
-// Execute execute action
+// Execute execute action. error is *ExitError having exit status of the outcome
 func (c *Context) Execute(command string) error {
+	if err := c.execute(command); err != nil {
+		return &ExitError{Code: exitStatus(err), Err: err}
+	}
+	return nil
+}
+
+func (c *Context) execute(command string) error {
+	if configCommands[command] {
+		if err := c.Lock(); err != nil {
+			return configError(err)
+		}
+		defer c.Unlock()
+	}
 	if err := c.LoadConfig(); err != nil {
-		return err
+		return configError(err)
 	}
 
 	switch command {
 	case "show":
 		a := &Action{
-			printer: NewPrinter(c.Option.Show.printFlag(), os.Stdout),
+			printer: c.recordPrinter(c.Option.Show.printFlag()),
 		}
 		return a.ShowUser(c)
 	case "set":
 		a := &Action{
-			printer: NewPrinter(PrintDefault, os.Stdout),
+			printer: c.recordPrinter(PrintDefault),
 		}
 		return a.SetUser(c)
 	case "delete":
 		a := &Action{
-			printer: NewPrinter(PrintDefault, os.Stdout),
+			printer: c.recordPrinter(PrintDefault),
 		}
 		return a.DeleteUser(c)
 	case "local":
 		a := &Action{
-			printer: NewPrinter(c.Option.Local.printFlag(), os.Stdout),
+			printer: c.recordPrinter(c.Option.Local.printFlag()),
 		}
 		return a.ShowLocalUser(c)
 	case "list":
 		a := &Action{
-			printer: NewPrinter(c.Option.List.printFlag(), os.Stdout),
+			printer: c.recordPrinter(c.Option.List.printFlag()),
 		}
 		return a.ListUsers(c)
 	case "sync":
-		var writer io.Writer
-		if c.Option.Sync.Quiet {
-			writer = &nullIO{}
-		} else {
-			writer = os.Stdout
-		}
 		a := &Action{
-			printer: NewPrinter(PrintDefault, writer),
+			printer: c.recordPrinter(PrintDefault),
+		}
+		if c.Option.Sync.Quiet {
+			a.printer = NewPrinter(PrintDefault, &nullIO{})
 		}
 		return a.SyncGitUserToLocal(c)
 	case "print":
@@ -118,11 +317,162 @@ func (c *Context) Execute(command string) error {
 			printer: NewPrinter(PrintDefault, os.Stdout),
 		}
 		return a.Print(c)
+	case "explain":
+		a := &Action{
+			printer: NewPrinter(PrintDefault, os.Stdout),
+		}
+		return a.Explain(c)
+	case "export-includeif":
+		a := &Action{
+			printer: NewPrinter(PrintDefault, os.Stdout),
+		}
+		return a.ExportIncludeIf(c)
+	case "hook install":
+		a := &Action{
+			printer: NewPrinter(PrintDefault, os.Stdout),
+		}
+		return a.InstallHook(c)
+	case "hook check":
+		a := &Action{
+			printer: NewPrinter(PrintDefault, os.Stderr),
+		}
+		return a.CheckHook(c)
+	case "audit":
+		a := &Action{
+			printer: NewPrinter(PrintDefault, os.Stdout),
+		}
+		return a.Audit(c)
+	case "doctor":
+		a := &Action{
+			printer: NewPrinter(PrintDefault, os.Stdout),
+		}
+		return a.Doctor(c)
+	case "config path":
+		a := &Action{
+			printer: NewPrinter(PrintDefault, os.Stdout),
+		}
+		return a.ConfigPath(c)
+	case "import":
+		a := &Action{
+			printer: NewPrinter(PrintDefault, os.Stdout),
+		}
+		return a.Import(c)
+	case "export":
+		a := &Action{
+			printer: NewPrinter(PrintDefault, os.Stdout),
+		}
+		return a.Export(c)
+	case "profile add":
+		a := &Action{
+			printer: NewPrinter(PrintDefault, os.Stdout),
+		}
+		return a.AddProfile(c)
+	case "profile edit":
+		a := &Action{
+			printer: NewPrinter(PrintDefault, os.Stdout),
+		}
+		return a.EditProfile(c)
+	case "profile rm":
+		a := &Action{
+			printer: NewPrinter(PrintDefault, os.Stdout),
+		}
+		return a.RemoveProfile(c)
+	case "profile list":
+		a := &Action{
+			printer: NewPrinter(PrintDefault, os.Stdout),
+		}
+		return a.ListProfiles(c)
 	}
 
 	return nil
 }
 
+// configPath path of config file written by git-user. format is chosen by extension.
+// $XDG_CONFIG_HOME/git-user/config is used by default, or ~/git-user.json of older version if exists
 func (c Context) configPath() (string, error) {
-	return homedir.Expand(c.Option.Config)
+	if c.Option.Config != "" {
+		return homedir.Expand(c.Option.Config)
+	}
+	dir, err := xdgConfigDir()
+	if err != nil {
+		return "", err
+	}
+	if path, ok := existingConfig(filepath.Join(dir, configFileName), configExts); ok {
+		return path, nil
+	}
+	legacy, err := homedir.Expand(legacyConfigBase)
+	if err != nil {
+		return "", err
+	}
+	if path, ok := existingConfig(legacy, legacyConfigExts); ok {
+		return path, nil
+	}
+	return filepath.Join(dir, configFileName), nil
+}
+
+// configFiles config files in load order, drop-in files of $XDG_CONFIG_HOME/git-user/conf.d then config file
+func (c Context) configFiles() ([]string, error) {
+	dir, err := xdgConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := dropInConfigs(filepath.Join(dir, dropInDirName))
+	if err != nil {
+		return nil, err
+	}
+	path, err := c.configPath()
+	if err != nil {
+		return nil, err
+	}
+	return append(files, path), nil
+}
+
+// git backend of directory, current directory if empty
+func (c Context) git(dir string) GitBackend {
+	if c.NewGit != nil {
+		return c.NewGit(dir)
+	}
+	if c.Option.GitBackend == BackendExec {
+		return &Git{Dir: dir}
+	}
+	return &NativeGit{Git: Git{Dir: dir}}
+}
+
+// repository current work tree with prioritized remotes
+func (c Context) repository(git GitBackend) (*Repository, error) {
+	path, err := git.GetTopLevel()
+	if err != nil {
+		return nil, err
+	}
+	remotes, err := git.GetRemotes()
+	if err != nil {
+		return nil, err
+	}
+	return &Repository{
+		Path:    path,
+		Remotes: remotes.Prioritize(c.remotePriority()),
+	}, nil
+}
+
+// recordPrinter printer of --output into stdout. messages are printed into stderr unless text
+func (c Context) recordPrinter(flag uint) *Printer {
+	return NewPrinter(flag, os.Stdout).Output(c.Option.Output, os.Stderr)
+}
+
+// noMatch ErrNoMatch with --strict. otherwise nothing matched is not failure
+func (c Context) noMatch() error {
+	if c.Option.Strict {
+		return ErrNoMatch
+	}
+	return nil
+}
+
+func (c Context) remotePriority() []string {
+	if len(c.Option.Remotes) > 0 {
+		return c.Option.Remotes
+	}
+	if len(c.Settings.Remotes) > 0 {
+		return c.Settings.Remotes
+	}
+	return defaultRemotes
 }
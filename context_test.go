@@ -0,0 +1,122 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestContext_LoadConfig(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "git-user_test")
+	defer os.RemoveAll(dir)
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	os.Setenv("XDG_CONFIG_HOME", dir)
+
+	dropIn := filepath.Join(dir, "git-user", "conf.d", "10-company.yaml")
+	os.MkdirAll(filepath.Dir(dropIn), 0755)
+	ioutil.WriteFile(dropIn, []byte(`version: 2
+profiles:
+  - {Profile: company, Name: Mike, Email: mike@company.com}
+users:
+  - {URL: "git@github.com:company/*", Profile: company}
+  - {URL: "git@github.com:shared/*", Profile: company}
+`), 0644)
+	config := filepath.Join(dir, "config.json")
+	ioutil.WriteFile(config, []byte(`[
+  {"URL": "git@github.com:shared/*", "Name": "Mike", "Email": "mike@example.com", "SigningKey": ""}
+]`), 0644)
+
+	c := NewContext()
+	c.Option.Config = config
+	if err := c.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if want := []string{dropIn, config}; !reflect.DeepEqual(c.Files, want) {
+		t.Errorf("LoadConfig() files = %v, want %v", c.Files, want)
+	}
+	var got [][2]string
+	for _, u := range c.Users {
+		got = append(got, [2]string{u.URL, u.Email})
+	}
+	want := [][2]string{{"git@github.com:company/*", "mike@company.com"}, {"git@github.com:shared/*", "mike@example.com"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadConfig() users = %v, want %v", got, want)
+	}
+	if _, err := os.Stat(config + ".v1.bak"); err != nil {
+		t.Errorf("LoadConfig() backup error = %v", err)
+	}
+	if _, err := os.Stat(config + backupSuffix); !os.IsNotExist(err) {
+		t.Errorf("LoadConfig() wrote second backup, error = %v", err)
+	}
+	if c.lock != nil {
+		t.Errorf("LoadConfig() did not unlock after migration")
+	}
+
+	data, _ := ioutil.ReadFile(config)
+	doc, err := decodeConfig(data, FormatJSON)
+	if err != nil {
+		t.Fatalf("decodeConfig() error = %v", err)
+	}
+	if len(doc.Users) != 1 || len(doc.Profiles) != 1 || doc.Profiles[0].Profile != "mike@example.com" {
+		t.Errorf("SaveConfig() wrote drop-in rules %s", data)
+	}
+}
+
+func TestContext_SaveConfig(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "git-user_test")
+	defer os.RemoveAll(dir)
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	os.Setenv("XDG_CONFIG_HOME", dir)
+
+	// config file symlinked into dotfiles
+	config := filepath.Join(dir, "dotfiles", "git-user.json")
+	os.MkdirAll(filepath.Dir(config), 0755)
+	previous := []byte(`{"version": 2, "profiles": [], "users": []}`)
+	ioutil.WriteFile(config, previous, 0600)
+	link := filepath.Join(dir, "git-user.json")
+	os.Symlink(config, link)
+
+	c := NewContext()
+	c.Option.Config = link
+	if err := c.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	c.Users.Put(&User{URL: "git@github.com:*", Profile: "mike", Name: "Mike", Email: "mike@example.com"})
+	c.Profiles.Put(&Profile{Profile: "mike", Identity: Identity{Name: "Mike", Email: "mike@example.com"}})
+	if err := c.SaveConfig(); err != nil {
+		t.Fatalf("SaveConfig() error = %v", err)
+	}
+
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("SaveConfig() replaced symlink")
+	}
+	if info, _ := os.Stat(config); info.Mode().Perm() != 0600 {
+		t.Errorf("SaveConfig() mode = %v, want 0600", info.Mode().Perm())
+	}
+	if data, _ := ioutil.ReadFile(config + ".bak"); !reflect.DeepEqual(data, previous) {
+		t.Errorf("SaveConfig() backup = %s, want %s", data, previous)
+	}
+	if c.lock != nil {
+		t.Errorf("SaveConfig() lock is not released")
+	}
+}
+
+func TestContext_Lock_legacy(t *testing.T) {
+	home, cleanup := isolatedHome(t)
+	defer cleanup()
+	ioutil.WriteFile(filepath.Join(home, "git-user.json"), []byte("[]"), 0644)
+
+	c := NewContext()
+	if err := c.Lock(); err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	defer c.Unlock()
+	if _, err := os.Stat(filepath.Join(home, "git-user.json.lock")); err != nil {
+		t.Errorf("Lock() lock file error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".config")); !os.IsNotExist(err) {
+		t.Errorf("Lock() created config dir of XDG for legacy config file")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+)
+
+// exit status of git-user
+const (
+	ExitOK = 0
+	// ExitFailure command failed, e.g. hook rejected or audit found wrong commits
+	ExitFailure = 1
+	// ExitUsage invalid command line
+	ExitUsage = 2
+	// ExitNoMatch nothing matched current repository with --strict
+	ExitNoMatch = 3
+	// ExitOutsideWorkTree current directory is outside git work tree
+	ExitOutsideWorkTree = 4
+	// ExitConfig config file of git-user can not be read or written
+	ExitConfig = 5
+	// ExitGit git command or git config failed
+	ExitGit = 6
+)
+
+// outcomes already reported by action
+var (
+	// ErrOutsideWorkTree current directory is outside git work tree
+	ErrOutsideWorkTree = errors.New("outside work tree")
+	// ErrNoMatch nothing matched current repository, failure with --strict
+	ErrNoMatch = errors.New("no git-user config matched")
+	// ErrSyncFailed sync of current repository failed, errors of which are printed by action
+	ErrSyncFailed = errors.New("failed to sync")
+)
+
+// ExitError error with exit status
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap cause of error
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// Reported error is already reported by action, so no need to print it
+func (e *ExitError) Reported() bool {
+	return errors.Is(e.Err, ErrOutsideWorkTree) || errors.Is(e.Err, ErrNoMatch) || errors.Is(e.Err, ErrSyncFailed)
+}
+
+// configError error of config file of git-user
+func configError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ExitError{Code: ExitConfig, Err: err}
+}
+
+// exitStatus exit status of error returned by action
+func exitStatus(err error) int {
+	var exitErr *ExitError
+	var gitErr *GitError
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &exitErr):
+		return exitErr.Code
+	case errors.Is(err, ErrNoMatch):
+		return ExitNoMatch
+	case errors.Is(err, ErrOutsideWorkTree), errors.Is(err, ErrNotRepository):
+		return ExitOutsideWorkTree
+	case errors.As(err, &gitErr), errors.Is(err, ErrLocked), errors.Is(err, ErrGitNotFound):
+		return ExitGit
+	}
+	return ExitFailure
+}
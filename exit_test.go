@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func Test_exitStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, ExitOK},
+		{"failure", errors.New("git-user audit failed"), ExitFailure},
+		{"no match", ErrNoMatch, ExitNoMatch},
+		{"outside work tree", ErrOutsideWorkTree, ExitOutsideWorkTree},
+		{"not repository", &GitError{Args: []string{"config"}, Err: ErrNotRepository}, ExitOutsideWorkTree},
+		{"config", configError(errors.New("bad config")), ExitConfig},
+		{"git", &GitError{Args: []string{"config"}, Err: errors.New("exit status 128")}, ExitGit},
+		{"locked", fmt.Errorf("could not lock config file: %w", ErrLocked), ExitGit},
+		{"git not found", fmt.Errorf("failed to sync: %w", &GitError{Args: []string{"config"}, Err: ErrGitNotFound}), ExitGit},
+		{"exit error", &ExitError{Code: ExitConfig, Err: &GitError{Args: []string{"config"}, Err: ErrLocked}}, ExitConfig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitStatus(tt.err); got != tt.want {
+				t.Errorf("exitStatus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContext_Execute(t *testing.T) {
+	c := NewContext()
+	c.Option.Config = "testdata/notfound/config.json"
+	c.NewGit = func(string) GitBackend { return newFakeGit("") }
+	err := c.Execute("show")
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != ExitOutsideWorkTree || !exitErr.Reported() {
+		t.Errorf("Execute() error = %#v, want reported %d", err, ExitOutsideWorkTree)
+	}
+}
+
+func TestExitError_Reported(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		want     bool
+		wantCode int
+	}{
+		{"outside work tree", ErrOutsideWorkTree, true, ExitOutsideWorkTree},
+		{"no match", ErrNoMatch, true, ExitNoMatch},
+		{"sync failed", &ExitError{Code: ExitGit, Err: fmt.Errorf("%w: %v", ErrSyncFailed, ErrLocked)}, true, ExitGit},
+		{"git", &GitError{Args: []string{"config"}, Err: ErrLocked}, false, ExitGit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// wrapped like Context.Execute
+			err := &ExitError{Code: exitStatus(tt.err), Err: tt.err}
+			if got := err.Reported(); got != tt.want || err.Code != tt.wantCode {
+				t.Errorf("Reported() = %v, code %v, want %v, %v", got, err.Code, tt.want, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"strings"
+)
+
+// fakeGit in-memory GitBackend
+type fakeGit struct {
+	// workTree top level. outside work tree if empty
+	workTree string
+	remotes  Remotes
+	local    map[string]string
+	global   map[string]string
+	gitPath  string
+
+	commits  []*Commit
+	upstream bool
+	pushed   map[string]bool
+
+	// getErr error of reading config
+	getErr error
+	// setErr error of writing config
+	setErr error
+	// commitsErr error of listing commits
+	commitsErr error
+	// rewritten base, name and email of rewritten commits
+	rewritten []string
+}
+
+func newFakeGit(workTree string, remotes ...*Remote) *fakeGit {
+	return &fakeGit{
+		workTree: workTree,
+		remotes:  remotes,
+		local:    map[string]string{},
+		global:   map[string]string{},
+		pushed:   map[string]bool{},
+	}
+}
+
+func (g *fakeGit) IsInsideWorkTree() (bool, error) {
+	return g.workTree != "", nil
+}
+
+func (g *fakeGit) GetTopLevel() (string, error) {
+	if g.workTree == "" {
+		return "", ErrNotRepository
+	}
+	return g.workTree, nil
+}
+
+func (g *fakeGit) GetRemotes() (Remotes, error) {
+	if g.getErr != nil {
+		return nil, g.getErr
+	}
+	return g.remotes, nil
+}
+
+func (g *fakeGit) GetConfig(key string) (string, error) {
+	if value, err := g.GetLocalConfig(key); err == nil || !IsNotFound(err) {
+		return value, err
+	}
+	return g.GetGlobalConfig(key)
+}
+
+func (g *fakeGit) GetGlobalConfig(key string) (string, error) {
+	return g.get(g.global, key)
+}
+
+func (g *fakeGit) SetGlobalConfig(key, value string) error {
+	if g.setErr != nil {
+		return g.setErr
+	}
+	g.global[canonicalConfigKey(key)] = value
+	return nil
+}
+
+func (g *fakeGit) GetLocalConfig(key string) (string, error) {
+	if g.workTree == "" {
+		return "", ErrNotRepository
+	}
+	return g.get(g.local, key)
+}
+
+func (g *fakeGit) get(config map[string]string, key string) (string, error) {
+	if g.getErr != nil {
+		return "", g.getErr
+	}
+	value, ok := config[canonicalConfigKey(key)]
+	if !ok {
+		return "", ErrKeyNotFound
+	}
+	return value, nil
+}
+
+func (g *fakeGit) SetLocalConfig(key, value string) error {
+	if g.workTree == "" {
+		return ErrNotRepository
+	}
+	if g.setErr != nil {
+		return g.setErr
+	}
+	g.local[canonicalConfigKey(key)] = value
+	return nil
+}
+
+func (g *fakeGit) UnsetLocalConfig(key string) error {
+	if g.workTree == "" {
+		return ErrNotRepository
+	}
+	if g.setErr != nil {
+		return g.setErr
+	}
+	if _, ok := g.local[canonicalConfigKey(key)]; !ok {
+		return ErrKeyNotFound
+	}
+	delete(g.local, canonicalConfigKey(key))
+	return nil
+}
+
+func (g *fakeGit) GetGitPath(path string) (string, error) {
+	return g.gitPath + "/" + path, nil
+}
+
+// GetCommits all commits, or commits after base of `base^..HEAD`
+func (g *fakeGit) GetCommits(args ...string) ([]*Commit, error) {
+	if g.commitsErr != nil {
+		return nil, g.commitsErr
+	}
+	if len(args) > 0 && strings.HasSuffix(args[0], "^..HEAD") {
+		base := strings.TrimSuffix(args[0], "^..HEAD")
+		for i, commit := range g.commits {
+			if commit.Hash == base {
+				return g.commits[i:], nil
+			}
+		}
+	}
+	return g.commits, nil
+}
+
+func (g *fakeGit) HasUpstream() (bool, error) {
+	return g.upstream, nil
+}
+
+func (g *fakeGit) IsPushed(hash string) (bool, error) {
+	return g.pushed[hash], nil
+}
+
+func (g *fakeGit) HasParent(hash string) (bool, error) {
+	return len(g.commits) > 0 && g.commits[0].Hash != hash, nil
+}
+
+func (g *fakeGit) RewriteCommits(base, name, email string) error {
+	g.rewritten = []string{base, name, email}
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// suffix of backup of previous config file, and of lock file
+const (
+	backupSuffix = ".bak"
+	lockSuffix   = ".lock"
+)
+
+// lock of config file is waited up to lockTimeout
+var (
+	lockTimeout       = 10 * time.Second
+	lockRetryInterval = 50 * time.Millisecond
+)
+
+// errLocked lock is held by another process
+var errLocked = errors.New("locked")
+
+// fileLock advisory lock of file, held during load-modify-save cycle of config file
+type fileLock struct {
+	file *os.File
+	path string
+}
+
+// lockFile lock file of path exclusively, waiting for other git-user processes
+func lockFile(path string) (*fileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := tryLockFile(path)
+		if err == nil {
+			return &fileLock{file: f, path: path}, nil
+		}
+		if err != errLocked {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, lockTimeoutError(path)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// Unlock release lock
+func (l *fileLock) Unlock() error {
+	return unlockFile(l.file, l.path)
+}
+
+// resolveSymlink target of symlink, e.g. config file in dotfiles repository, which is written instead of replacing symlink
+func resolveSymlink(path string) string {
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		return real
+	}
+	return path
+}
+
+// writeFileAtomic write data into temporary file and rename it to path,
+// so that path has either previous or new content even if process is killed.
+// mode of existing file is kept
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// writeFileWithBackup write file atomically, previous content is kept in path with suffix, e.g. path.bak
+func writeFileWithBackup(path, suffix string, data, previous []byte, perm os.FileMode) error {
+	if previous != nil {
+		if err := writeFileAtomic(path+suffix, previous, perm); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(path, data, perm)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_writeFileWithBackup(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "git-user_test")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config")
+
+	tests := []struct {
+		data string
+		bak  string
+	}{
+		{"v1", ""},
+		{"v2", "v1"},
+		{"v3", "v2"},
+	}
+	for _, tt := range tests {
+		previous, _ := ioutil.ReadFile(path)
+		if err := writeFileWithBackup(path, backupSuffix, []byte(tt.data), previous, 0644); err != nil {
+			t.Fatalf("writeFileWithBackup() error = %v", err)
+		}
+		if data, _ := ioutil.ReadFile(path); string(data) != tt.data {
+			t.Errorf("writeFileWithBackup() = %s, want %s", data, tt.data)
+		}
+		if data, _ := ioutil.ReadFile(path + backupSuffix); string(data) != tt.bak {
+			t.Errorf("writeFileWithBackup() backup = %s, want %s", data, tt.bak)
+		}
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 2 {
+		t.Errorf("writeFileWithBackup() left temporary files %d", len(files))
+	}
+}
+
+func Test_lockFile(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "git-user_test")
+	defer os.RemoveAll(dir)
+	defer func(timeout time.Duration) { lockTimeout = timeout }(lockTimeout)
+	lockTimeout = 100 * time.Millisecond
+	path := filepath.Join(dir, "git-user", "config")
+
+	lock, err := lockFile(path)
+	if err != nil {
+		t.Fatalf("lockFile() error = %v", err)
+	}
+	if _, err := lockFile(path); err == nil {
+		t.Errorf("lockFile() locked twice")
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		lock.Unlock()
+	}()
+	lock, err = lockFile(path)
+	if err != nil {
+		t.Fatalf("lockFile() error after unlock = %v", err)
+	}
+	lock.Unlock()
+}
@@ -1,83 +1,394 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
 	"os/exec"
+	"sort"
 	"strings"
 )
 
-// Git execution of git command
-type Git struct{}
+// git backend
+const (
+	BackendNative = "native"
+	BackendExec   = "exec"
+)
+
+// GitBackend git operations. Git runs git command, and NativeGit reads and writes git config in process.
+// getters of config return ErrKeyNotFound if key is not set
+type GitBackend interface {
+	IsInsideWorkTree() (bool, error)
+	GetTopLevel() (string, error)
+	GetRemotes() (Remotes, error)
+	GetConfig(key string) (string, error)
+	GetGlobalConfig(key string) (string, error)
+	SetGlobalConfig(key, value string) error
+	GetLocalConfig(key string) (string, error)
+	SetLocalConfig(key, value string) error
+	UnsetLocalConfig(key string) error
+	GetGitPath(path string) (string, error)
+	GetCommits(args ...string) ([]*Commit, error)
+	HasUpstream() (bool, error)
+	IsPushed(hash string) (bool, error)
+	HasParent(hash string) (bool, error)
+	RewriteCommits(base, name, email string) error
+}
+
+// kinds of git error
+var (
+	// ErrKeyNotFound config key is not set, which is not failure
+	ErrKeyNotFound = errors.New("key is not set")
+	// ErrLocked config file is locked by another process
+	ErrLocked = errors.New("config file is locked")
+	// ErrGitNotFound git command is not installed
+	ErrGitNotFound = errors.New("git command is not found")
+	// ErrNotRepository outside git repository
+	ErrNotRepository = errors.New("not a git repository")
+)
+
+// GitError failure of git command. Err is ErrKeyNotFound, ErrLocked, ErrGitNotFound, ErrNotRepository or error of command
+type GitError struct {
+	Args   []string
+	Stderr string
+	Err    error
+}
+
+func (e *GitError) Error() string {
+	message := fmt.Sprintf("git %s: %v", strings.Join(e.Args, " "), e.Err)
+	if e.Stderr != "" {
+		message += ": " + e.Stderr
+	}
+	return message
+}
+
+// Unwrap kind of error
+func (e *GitError) Unwrap() error {
+	return e.Err
+}
+
+// IsNotFound error is ErrKeyNotFound
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrKeyNotFound)
+}
+
+// newGitError classify error of git command by exit code and stderr
+func newGitError(args []string, stderr string, err error) error {
+	e := &GitError{Args: args, Stderr: strings.TrimSpace(stderr), Err: err}
+	var exitErr *exec.ExitError
+	code := -1
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+	}
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		e.Err = ErrGitNotFound
+	case strings.Contains(e.Stderr, "could not lock config file"):
+		e.Err = ErrLocked
+	case strings.Contains(e.Stderr, "not a git repository"), strings.Contains(e.Stderr, "can only be used inside a git repository"):
+		e.Err = ErrNotRepository
+	case args[0] == "config" && code == 1 && e.Stderr == "":
+		// `git config --get` of unset key
+		e.Err = ErrKeyNotFound
+	case args[0] == "config" && code == 5 && (contains(args, "--unset") || contains(args, "--unset-all")):
+		e.Err = ErrKeyNotFound
+	}
+	return e
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
 
-// IsInsideWorkTree `git rev-parse --is-inside-work-tree`
-func (*Git) IsInsideWorkTree() bool {
-	cmd := exec.Command("git", "rev-parse", "--is-inside-work-tree")
+// run run git command, and return stdout without trailing newline
+func (g *Git) run(args ...string) (string, error) {
+	cmd := g.command(args...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
 	out, err := cmd.Output()
 	if err != nil {
-		return false
+		return "", newGitError(args, stderr.String(), err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// verify run git command, which exits with 1 if false
+func (g *Git) verify(args ...string) (bool, error) {
+	_, err := g.run(args...)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Git execution of git command
+type Git struct {
+	// Dir is working directory of git command. current directory if empty
+	Dir string
+}
+
+func (g *Git) command(args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = g.Dir
+	return cmd
+}
+
+// Remote is git remote
+type Remote struct {
+	Name string
+	URL  string
+}
+
+// Remotes is slice of remote
+type Remotes []*Remote
+
+// Prioritize sort remotes by names order. unlisted remotes follow in original order
+func (rs Remotes) Prioritize(names []string) Remotes {
+	rank := func(r *Remote) int {
+		for i, name := range names {
+			if r.Name == name {
+				return i
+			}
+		}
+		return len(names)
+	}
+	sorted := make(Remotes, len(rs))
+	copy(sorted, rs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return rank(sorted[i]) < rank(sorted[j])
+	})
+	return sorted
+}
+
+func (rs Remotes) index(remote *Remote) int {
+	for i, r := range rs {
+		if r == remote {
+			return i
+		}
+	}
+	return len(rs)
+}
+
+// Commit is git commit
+type Commit struct {
+	Hash           string
+	AuthorName     string
+	AuthorEmail    string
+	CommitterName  string
+	CommitterEmail string
+	Subject        string
+}
+
+// Matches author and committer email are the email
+func (c *Commit) Matches(email string) bool {
+	return strings.EqualFold(c.AuthorEmail, email) && strings.EqualFold(c.CommitterEmail, email)
+}
+
+// commit log format. fields are separated by unit separator
+const commitFormat = "%H%x1f%an%x1f%ae%x1f%cn%x1f%ce%x1f%s"
+
+func parseCommits(out string) []*Commit {
+	var commits []*Commit
+	for _, line := range strings.Split(strings.Trim(out, "\n"), "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 6 {
+			continue
+		}
+		commits = append(commits, &Commit{
+			Hash:           fields[0],
+			AuthorName:     fields[1],
+			AuthorEmail:    fields[2],
+			CommitterName:  fields[3],
+			CommitterEmail: fields[4],
+			Subject:        fields[5],
+		})
+	}
+	return commits
+}
+
+// IsInsideWorkTree `git rev-parse --is-inside-work-tree`. false without error outside repository
+func (g *Git) IsInsideWorkTree() (bool, error) {
+	out, err := g.run("rev-parse", "--is-inside-work-tree")
+	if errors.Is(err, ErrNotRepository) {
+		return false, nil
+	}
+	return out == "true", err
+}
+
+// GetTopLevel `git rev-parse --show-toplevel`
+func (g *Git) GetTopLevel() (string, error) {
+	return g.run("rev-parse", "--show-toplevel")
+}
+
+// GetRemotes `git config --get-regexp ^remote\..*\.url$`
+func (g *Git) GetRemotes() (Remotes, error) {
+	out, err := g.run("config", "--get-regexp", `^remote\..*\.url$`)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var remotes Remotes
+	for _, line := range strings.Split(out, "\n") {
+		kv := strings.SplitN(line, " ", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(kv[0], "remote."), ".url")
+		remotes = append(remotes, &Remote{Name: name, URL: kv[1]})
+	}
+	return remotes, nil
+}
+
+// GetConfig `git config --get $key`
+func (g *Git) GetConfig(key string) (string, error) {
+	return g.run("config", "--get", key)
+}
+
+// GetGlobalConfig `git config --global --get $key`
+func (g *Git) GetGlobalConfig(key string) (string, error) {
+	return g.run("config", "--global", "--get", key)
+}
+
+// SetGlobalConfig `git config --global $key $value`
+func (g *Git) SetGlobalConfig(key, value string) error {
+	_, err := g.run("config", "--global", key, value)
+	return err
+}
+
+// GetGitPath `git rev-parse --git-path $path`
+func (g *Git) GetGitPath(path string) (string, error) {
+	return g.run("rev-parse", "--git-path", path)
+}
+
+// GetCommits `git log --reverse --topo-order $args...` oldest first
+func (g *Git) GetCommits(args ...string) ([]*Commit, error) {
+	out, err := g.run(append([]string{"log", "--reverse", "--topo-order", "--format=" + commitFormat}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	return parseCommits(out), nil
+}
+
+// HasUpstream `git rev-parse --verify @{upstream}`. false on detached HEAD or branch without upstream,
+// which `@{upstream}` fails with exit status 128
+func (g *Git) HasUpstream() (bool, error) {
+	branch, err := g.run("symbolic-ref", "--quiet", "HEAD")
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	upstream, err := g.run("for-each-ref", "--format=%(upstream)", branch)
+	if err != nil || upstream == "" {
+		return false, err
+	}
+	return g.verify("rev-parse", "--verify", "--quiet", upstream)
+}
+
+// IsPushed `git branch --remotes --contains $hash` is not empty
+func (g *Git) IsPushed(hash string) (bool, error) {
+	out, err := g.run("branch", "--remotes", "--contains", hash)
+	return strings.TrimSpace(out) != "", err
+}
+
+// HasParent `git rev-parse --verify $hash^`
+func (g *Git) HasParent(hash string) (bool, error) {
+	return g.verify("rev-parse", "--verify", "--quiet", hash+"^")
+}
+
+// RewriteCommits rebase commits after base (or from root if base is empty),
+// amending author and committer with name and email
+func (g *Git) RewriteCommits(base, name, email string) error {
+	amend := "git commit --amend --no-edit --no-verify --allow-empty --author=" + shellQuote(fmt.Sprintf("%s <%s>", name, email))
+	args := []string{"rebase", "--rebase-merges", "--autostash", "--exec", amend}
+	if base == "" {
+		args = append(args, "--root")
+	} else {
+		args = append(args, base)
 	}
-	return strings.Trim(string(out), "\n") == "true"
+	cmd := g.command(args...)
+	cmd.Env = append(os.Environ(),
+		"GIT_COMMITTER_NAME="+name,
+		"GIT_COMMITTER_EMAIL="+email,
+		"GIT_EDITOR=true",
+	)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return newGitError(args, "", err)
+	}
+	return nil
+}
+
+// GetLocalConfig `git config --local --get $key`
+func (g *Git) GetLocalConfig(key string) (string, error) {
+	return g.run("config", "--local", "--get", key)
+}
+
+// SetLocalConfig `git config --local $key $value`
+func (g *Git) SetLocalConfig(key, value string) error {
+	_, err := g.run("config", "--local", key, value)
+	return err
 }
 
-// GetRemoteOriginURL `git config --get remote.origin.url`
-func (*Git) GetRemoteOriginURL() string {
-	cmd := exec.Command("git", "config", "--get", "remote.origin.url")
-	out, _ := cmd.Output()
-	return strings.Trim(string(out), "\n")
+// UnsetLocalConfig `git config --local --unset-all $key`
+func (g *Git) UnsetLocalConfig(key string) error {
+	_, err := g.run("config", "--local", "--unset-all", key)
+	return err
 }
 
 // GetLocalUserName `git config --local --get user.name`
-func (*Git) GetLocalUserName() string {
-	cmd := exec.Command("git", "config", "--local", "--get", "user.name")
-	out, _ := cmd.Output()
-	return strings.Trim(string(out), "\n")
+func (g *Git) GetLocalUserName() (string, error) {
+	return g.GetLocalConfig("user.name")
 }
 
 // SetLocalUserName `git config --local user.name $name`
-func (*Git) SetLocalUserName(name string) error {
-	cmd := exec.Command("git", "config", "--local", "user.name", name)
-	return cmd.Run()
+func (g *Git) SetLocalUserName(name string) error {
+	return g.SetLocalConfig("user.name", name)
 }
 
-// UnsetLocalUserName git config --local --unset-all user.name
-func (*Git) UnsetLocalUserName() error {
-	cmd := exec.Command("git", "config", "--local", "--unset-all", "user.name")
-	return cmd.Run()
+// UnsetLocalUserName `git config --local --unset-all user.name`
+func (g *Git) UnsetLocalUserName() error {
+	return g.UnsetLocalConfig("user.name")
 }
 
 // GetLocalUserEmail `git config --local --get user.email`
-func (*Git) GetLocalUserEmail() string {
-	cmd := exec.Command("git", "config", "--local", "--get", "user.email")
-	out, _ := cmd.Output()
-	return strings.Trim(string(out), "\n")
+func (g *Git) GetLocalUserEmail() (string, error) {
+	return g.GetLocalConfig("user.email")
 }
 
 // SetLocalUserEmail `git config --local user.email $email`
-func (*Git) SetLocalUserEmail(email string) error {
-	cmd := exec.Command("git", "config", "--local", "user.email", email)
-	return cmd.Run()
+func (g *Git) SetLocalUserEmail(email string) error {
+	return g.SetLocalConfig("user.email", email)
 }
 
 // UnsetLocalUserEmail `git config --local --unset-all user.email`
-func (*Git) UnsetLocalUserEmail() error {
-	cmd := exec.Command("git", "config", "--local", "--unset-all", "user.email")
-	return cmd.Run()
+func (g *Git) UnsetLocalUserEmail() error {
+	return g.UnsetLocalConfig("user.email")
 }
 
 // GetLocalUserSigningKey `git config --local --get user.signingkey`
-func (*Git) GetLocalUserSigningKey() string {
-	cmd := exec.Command("git", "config", "--local", "--get", "user.signingkey")
-	out, _ := cmd.Output()
-	return strings.Trim(string(out), "\n")
+func (g *Git) GetLocalUserSigningKey() (string, error) {
+	return g.GetLocalConfig("user.signingkey")
 }
 
 // SetLocalUserSigningKey `git config --local user.signingkey $signingkey`
-func (*Git) SetLocalUserSigningKey(signingkey string) error {
-	cmd := exec.Command("git", "config", "--local", "user.signingkey", signingkey)
-	return cmd.Run()
+func (g *Git) SetLocalUserSigningKey(signingkey string) error {
+	return g.SetLocalConfig("user.signingkey", signingkey)
 }
 
 // UnsetLocalUserSigningKey `git config --local --unset-all user.signingkey`
-func (*Git) UnsetLocalUserSigningKey() error {
-	cmd := exec.Command("git", "config", "--local", "--unset-all", "user.signingkey")
-	return cmd.Run()
+func (g *Git) UnsetLocalUserSigningKey() error {
+	return g.UnsetLocalConfig("user.signingkey")
 }
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -59,7 +62,11 @@ func TestGit_GetLocalUserEmail(t *testing.T) {
 			gi := &Git{}
 			fn := tt.init()
 			defer fn()
-			if got := gi.GetLocalUserEmail(); got != tt.want {
+			got, err := gi.GetLocalUserEmail()
+			if err != nil && !IsNotFound(err) {
+				t.Errorf("GetLocalUserEmail() error = %v", err)
+			}
+			if got != tt.want {
 				t.Errorf("GetLocalUserEmail() = %v, want %v", got, tt.want)
 			}
 		})
@@ -94,7 +101,11 @@ func TestGit_GetLocalUserName(t *testing.T) {
 			gi := &Git{}
 			fn := tt.init()
 			defer fn()
-			if got := gi.GetLocalUserName(); got != tt.want {
+			got, err := gi.GetLocalUserName()
+			if err != nil && !IsNotFound(err) {
+				t.Errorf("GetLocalUserName() error = %v", err)
+			}
+			if got != tt.want {
 				t.Errorf("GetLocalUserName() = %v, want %v", got, tt.want)
 			}
 		})
@@ -129,43 +140,12 @@ func TestGit_GetLocalUserSigningKey(t *testing.T) {
 			gi := &Git{}
 			fn := tt.init()
 			defer fn()
-			if got := gi.GetLocalUserSigningKey(); got != tt.want {
-				t.Errorf("GetLocalUserSigningKey() = %v, want %v", got, tt.want)
+			got, err := gi.GetLocalUserSigningKey()
+			if err != nil && !IsNotFound(err) {
+				t.Errorf("GetLocalUserSigningKey() error = %v", err)
 			}
-		})
-	}
-}
-
-func TestGit_GetRemoteOriginURL(t *testing.T) {
-	tests := []struct {
-		name string
-		want string
-		init func() func()
-	}{
-		{
-			"valid",
-			"git@example.com:foo/bar",
-			func() func() {
-				fn := insideWorkTree()
-				exec.Command("git", "remote", "add", "origin", "git@example.com:foo/bar").Run()
-				return fn
-			},
-		},
-		{
-			"invalid",
-			"",
-			func() func() {
-				return insideWorkTree()
-			},
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			gi := &Git{}
-			fn := tt.init()
-			defer fn()
-			if got := gi.GetRemoteOriginURL(); got != tt.want {
-				t.Errorf("GetRemoteOriginURL() got = %v, want %v", got, tt.want)
+			if got != tt.want {
+				t.Errorf("GetLocalUserSigningKey() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -197,7 +177,11 @@ func TestGit_IsInsideWorkTree(t *testing.T) {
 			gi := &Git{}
 			fn := tt.init()
 			defer fn()
-			if got := gi.IsInsideWorkTree(); got != tt.want {
+			got, err := gi.IsInsideWorkTree()
+			if err != nil && !IsNotFound(err) {
+				t.Errorf("IsInsideWorkTree() error = %v", err)
+			}
+			if got != tt.want {
 				t.Errorf("IsInsideWorkTree() got = %v, want %v", got, tt.want)
 			}
 		})
@@ -243,7 +227,7 @@ func TestGit_SetLocalUserEmail(t *testing.T) {
 			if (err != nil) != tt.wantErr {
 				t.Errorf("SetLocalUserEmail() error = %v, wantErr %v", err, tt.wantErr)
 			} else {
-				if got := gi.GetLocalUserEmail(); got != tt.want {
+				if got, _ := gi.GetLocalUserEmail(); got != tt.want {
 					t.Errorf("GetLocalUserEmail() = %v, want %v", got, tt.want)
 				}
 			}
@@ -290,7 +274,7 @@ func TestGit_SetLocalUserName(t *testing.T) {
 			if (err != nil) != tt.wantErr {
 				t.Errorf("SetLocalUserName() error = %v, wantErr %v", err, tt.wantErr)
 			} else {
-				if got := gi.GetLocalUserName(); got != tt.want {
+				if got, _ := gi.GetLocalUserName(); got != tt.want {
 					t.Errorf("GetLocalUserName() = %v, want %v", got, tt.want)
 				}
 			}
@@ -338,7 +322,7 @@ func TestGit_SetLocalUserSigningKey(t *testing.T) {
 			if (err != nil) != tt.wantErr {
 				t.Errorf("SetLocalUserSigningKey() error = %v, wantErr %v", err, tt.wantErr)
 			} else {
-				if got := gi.GetLocalUserSigningKey(); got != tt.want {
+				if got, _ := gi.GetLocalUserSigningKey(); got != tt.want {
 					t.Errorf("GetLocalUserSigningKey() = %v, want %v", got, tt.want)
 				}
 			}
@@ -380,7 +364,11 @@ func TestGit_UnsetLocalUserEmail(t *testing.T) {
 			if err := gi.UnsetLocalUserEmail(); (err != nil) != tt.wantErr {
 				t.Errorf("UnsetLocalUserEmail() error = %v, wantErr %v", err, tt.wantErr)
 			}
-			if got := gi.GetLocalUserEmail(); got != tt.want {
+			got, err := gi.GetLocalUserEmail()
+			if err != nil && !IsNotFound(err) {
+				t.Errorf("GetLocalUserEmail() error = %v", err)
+			}
+			if got != tt.want {
 				t.Errorf("GetLocalUserEmail() got = %v, want %v", got, tt.want)
 			}
 		})
@@ -421,7 +409,11 @@ func TestGit_UnsetLocalUserName(t *testing.T) {
 			if err := gi.UnsetLocalUserName(); (err != nil) != tt.wantErr {
 				t.Errorf("UnsetLocalUserName() error = %v, wantErr %v", err, tt.wantErr)
 			}
-			if got := gi.GetLocalUserName(); got != tt.want {
+			got, err := gi.GetLocalUserName()
+			if err != nil && !IsNotFound(err) {
+				t.Errorf("GetLocalUserName() error = %v", err)
+			}
+			if got != tt.want {
 				t.Errorf("UnsetLocalUserEmail() got = %v, want %v", got, tt.want)
 			}
 		})
@@ -462,9 +454,219 @@ func TestGit_UnsetLocalUserSigningKey(t *testing.T) {
 			if err := gi.UnsetLocalUserSigningKey(); (err != nil) != tt.wantErr {
 				t.Errorf("UnsetLocalUserSigningKey() error = %v, wantErr %v", err, tt.wantErr)
 			}
-			if got := gi.GetLocalUserSigningKey(); got != tt.want {
+			got, err := gi.GetLocalUserSigningKey()
+			if err != nil && !IsNotFound(err) {
+				t.Errorf("GetLocalUserSigningKey() error = %v", err)
+			}
+			if got != tt.want {
 				t.Errorf("GetLocalUserSigningKey() got = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestGit_GetRemotes(t *testing.T) {
+	tests := []struct {
+		name string
+		want Remotes
+		init func() func()
+	}{
+		{
+			"some remotes",
+			Remotes{
+				&Remote{Name: "origin", URL: "git@example.com:tsuty/bar"},
+				&Remote{Name: "upstream", URL: "git@example.com:foo/bar"},
+			},
+			func() func() {
+				fn := insideWorkTree()
+				exec.Command("git", "remote", "add", "origin", "git@example.com:tsuty/bar").Run()
+				exec.Command("git", "remote", "add", "upstream", "git@example.com:foo/bar").Run()
+				return fn
+			},
+		},
+		{
+			"no remote",
+			nil,
+			func() func() {
+				return insideWorkTree()
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gi := &Git{}
+			fn := tt.init()
+			defer fn()
+			got, err := gi.GetRemotes()
+			if err != nil {
+				t.Errorf("GetRemotes() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetRemotes() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemotes_Prioritize(t *testing.T) {
+	remotes := Remotes{
+		&Remote{Name: "github", URL: "git@github.com:tsuty/bar"},
+		&Remote{Name: "origin", URL: "git@example.com:tsuty/bar"},
+		&Remote{Name: "upstream", URL: "git@example.com:foo/bar"},
+	}
+	tests := []struct {
+		name  string
+		names []string
+		want  []string
+	}{
+		{
+			"upstream before origin",
+			[]string{"upstream", "origin"},
+			[]string{"upstream", "origin", "github"},
+		},
+		{
+			"no priority",
+			nil,
+			[]string{"github", "origin", "upstream"},
+		},
+		{
+			"unknown name",
+			[]string{"gitlab", "origin"},
+			[]string{"origin", "github", "upstream"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, r := range remotes.Prioritize(tt.names) {
+				got = append(got, r.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Prioritize() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseCommits(t *testing.T) {
+	out := "aaa\x1fMike\x1fmike@example.com\x1fMike\x1fmike@example.com\x1ffirst\n" +
+		"bbb\x1fSulley\x1fsulley@example.com\x1fMike\x1fmike@example.com\x1fsecond: with \x1e\n"
+	want := []*Commit{
+		{Hash: "aaa", AuthorName: "Mike", AuthorEmail: "mike@example.com", CommitterName: "Mike", CommitterEmail: "mike@example.com", Subject: "first"},
+		{Hash: "bbb", AuthorName: "Sulley", AuthorEmail: "sulley@example.com", CommitterName: "Mike", CommitterEmail: "mike@example.com", Subject: "second: with \x1e"},
+	}
+	if got := parseCommits(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseCommits() = %v, want %v", got, want)
+	}
+	if got := parseCommits(""); got != nil {
+		t.Errorf("parseCommits() = %v, want nil", got)
+	}
+}
+
+func TestCommit_Matches(t *testing.T) {
+	tests := []struct {
+		name   string
+		commit *Commit
+		want   bool
+	}{
+		{"match", &Commit{AuthorEmail: "mike@example.com", CommitterEmail: "Mike@Example.com"}, true},
+		{"author differs", &Commit{AuthorEmail: "mike@home.example.com", CommitterEmail: "mike@example.com"}, false},
+		{"committer differs", &Commit{AuthorEmail: "mike@example.com", CommitterEmail: "mike@home.example.com"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.commit.Matches("mike@example.com"); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGit_errors(t *testing.T) {
+	fn := insideWorkTree()
+	defer fn()
+	exec.Command("git", "config", "--local", "user.name", "Mike").Run()
+
+	for _, gi := range []GitBackend{&Git{}, &NativeGit{}} {
+		if _, err := gi.GetLocalConfig("user.email"); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("%T GetLocalConfig() error = %v, want %v", gi, err, ErrKeyNotFound)
+		}
+		if err := gi.UnsetLocalConfig("user.email"); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("%T UnsetLocalConfig() error = %v, want %v", gi, err, ErrKeyNotFound)
+		}
+
+		ioutil.WriteFile(".git/config.lock", nil, 0644)
+		if err := gi.SetLocalConfig("user.name", "Lock"); !errors.Is(err, ErrLocked) {
+			t.Errorf("%T SetLocalConfig() error = %v, want %v", gi, err, ErrLocked)
+		}
+		os.Remove(".git/config.lock")
+		if got, err := gi.GetLocalConfig("user.name"); got != "Mike" || err != nil {
+			t.Errorf("%T GetLocalConfig() = %v, %v", gi, got, err)
+		}
+	}
+
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", "")
+	defer os.Setenv("PATH", path)
+	if _, err := (&Git{}).GetTopLevel(); !errors.Is(err, ErrGitNotFound) {
+		t.Errorf("GetTopLevel() error = %v, want %v", err, ErrGitNotFound)
+	}
+}
+
+func TestGit_errorsBadConfig(t *testing.T) {
+	fn := insideWorkTree()
+	defer fn()
+	ioutil.WriteFile(".git/config", []byte("[core\n"), 0644)
+
+	for _, gi := range []GitBackend{&Git{}, &NativeGit{}} {
+		var gitErr *GitError
+		if _, err := gi.GetLocalConfig("user.name"); !errors.As(err, &gitErr) || exitStatus(err) != ExitGit {
+			t.Errorf("%T GetLocalConfig() error = %v, want *GitError", gi, err)
+		}
+		if err := gi.SetLocalConfig("user.name", "Mike"); !errors.As(err, &gitErr) || exitStatus(err) != ExitGit {
+			t.Errorf("%T SetLocalConfig() error = %v, want *GitError", gi, err)
+		}
+	}
+}
+
+func TestGit_HasUpstream(t *testing.T) {
+	fn := insideWorkTree()
+	defer fn()
+	git := func(args ...string) {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+	}
+	git("-c", "user.name=Mike", "-c", "user.email=mike@example.com", "commit", "--allow-empty", "-m", "init")
+
+	gi := &Git{}
+	if got, err := gi.HasUpstream(); got || err != nil {
+		t.Errorf("HasUpstream() without upstream = %v, %v", got, err)
+	}
+	git("branch", "base")
+	git("branch", "--set-upstream-to", "base")
+	if got, err := gi.HasUpstream(); !got || err != nil {
+		t.Errorf("HasUpstream() = %v, %v", got, err)
+	}
+	git("checkout", "--quiet", "--detach")
+	if got, err := gi.HasUpstream(); got || err != nil {
+		t.Errorf("HasUpstream() detached = %v, %v", got, err)
+	}
+}
+
+func TestGit_errorsOutsideWorkTree(t *testing.T) {
+	fn := outsideWorkTree()
+	defer fn()
+
+	gi := &Git{}
+	if inside, err := gi.IsInsideWorkTree(); inside || err != nil {
+		t.Errorf("IsInsideWorkTree() = %v, %v", inside, err)
+	}
+	_, err := gi.GetLocalConfig("user.name")
+	if !errors.Is(err, ErrNotRepository) {
+		t.Errorf("GetLocalConfig() error = %v, want %v", err, ErrNotRepository)
+	}
+	if !strings.Contains(err.Error(), "can only be used inside a git repository") {
+		t.Errorf("GetLocalConfig() error = %v, want stderr", err)
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ConfigEntry is git config key and value
+type ConfigEntry struct {
+	Key   string
+	Value string
+}
+
+// ConfigMap ordered git config entries. it is json object keeping order of keys
+type ConfigMap []ConfigEntry
+
+// Get value of key
+func (m ConfigMap) Get(key string) (string, bool) {
+	for _, entry := range m {
+		if canonicalConfigKey(entry.Key) == canonicalConfigKey(key) {
+			return entry.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set replace value of key, or append it
+func (m *ConfigMap) Set(key, value string) {
+	for i, entry := range *m {
+		if canonicalConfigKey(entry.Key) == canonicalConfigKey(key) {
+			(*m)[i].Value = value
+			return
+		}
+	}
+	*m = append(*m, ConfigEntry{Key: key, Value: value})
+}
+
+// MarshalJSON marshal as json object
+func (m ConfigMap) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, entry := range m {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(entry.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON unmarshal json object in order of keys. bool and number values are converted into string,
+// e.g. `http.sslVerify: false` of yaml
+func (m *ConfigMap) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		*m = nil
+		return nil
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("git config must be json object: %s", data)
+	}
+	entries := ConfigMap{}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		key := token.(string)
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return err
+		}
+		switch v := value.(type) {
+		case string:
+			entries.Set(key, v)
+		case bool:
+			entries.Set(key, strconv.FormatBool(v))
+		case json.Number:
+			entries.Set(key, v.String())
+		default:
+			return fmt.Errorf("git config %s must be string, bool or number", key)
+		}
+	}
+	*m = entries
+	return nil
+}
+
+// canonicalConfigKey section and name of key are case insensitive, subsection is not
+func canonicalConfigKey(key string) string {
+	section, subsection, name := splitConfigKey(key)
+	if subsection == "" {
+		return strings.ToLower(section) + "." + strings.ToLower(name)
+	}
+	return strings.ToLower(section) + "." + subsection + "." + strings.ToLower(name)
+}
+
+var (
+	configSectionPattern = regexp.MustCompile(`^[A-Za-z0-9.-]+$`)
+	configNamePattern    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*$`)
+)
+
+// ValidConfigKey validate git config key. keys managed by git-user can not be used
+func ValidConfigKey(key string) error {
+	section, _, name := splitConfigKey(key)
+	if !configSectionPattern.MatchString(section) || !configNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid git config key %s", key)
+	}
+	for _, managed := range managedConfigKeys {
+		if canonicalConfigKey(managed) == canonicalConfigKey(key) {
+			return fmt.Errorf("%s is managed by git-user. use name, email or signing options", key)
+		}
+	}
+	return nil
+}
+
+// ParseConfigEntry parse `key=value`
+func ParseConfigEntry(s string) (ConfigEntry, error) {
+	i := strings.Index(s, "=")
+	if i < 0 {
+		return ConfigEntry{}, fmt.Errorf("git config must be key=value: %s", s)
+	}
+	entry := ConfigEntry{Key: strings.TrimSpace(s[:i]), Value: s[i+1:]}
+	return entry, ValidConfigKey(entry.Key)
+}
+
+// ConfigKeys local git config keys managed by git-user, including extra config keys of all users
+func (us Users) ConfigKeys() []string {
+	keys := append([]string{}, managedConfigKeys...)
+	seen := map[string]bool{}
+	for _, key := range keys {
+		seen[canonicalConfigKey(key)] = true
+	}
+	for _, u := range us {
+		if u.SSHKey != "" && !seen[canonicalConfigKey(sshCommandKey)] {
+			seen[canonicalConfigKey(sshCommandKey)] = true
+			keys = append(keys, sshCommandKey)
+		}
+		for _, entry := range u.Config {
+			if !seen[canonicalConfigKey(entry.Key)] {
+				seen[canonicalConfigKey(entry.Key)] = true
+				keys = append(keys, entry.Key)
+			}
+		}
+	}
+	return keys
+}
+
+// OwnsConfig value of local git config key is written by git-user. identity keys are always owned,
+// and extra config keys only if value is of any user, so hand-set values are not unset by sync
+func (us Users) OwnsConfig(key, value string) bool {
+	key = canonicalConfigKey(key)
+	for _, managed := range managedConfigKeys {
+		if canonicalConfigKey(managed) == key {
+			return true
+		}
+	}
+	for _, u := range us {
+		for _, entry := range u.GitConfig() {
+			if canonicalConfigKey(entry.Key) == key && entry.Value == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// signing format of gpg.format
+const (
+	SigningOpenPGP = "openpgp"
+	SigningSSH     = "ssh"
+	SigningX509    = "x509"
+)
+
+// managedConfigKeys local git config keys of identity. keys not in user identity are unset
+var managedConfigKeys = []string{
+	"user.name",
+	"user.email",
+	"user.signingkey",
+	"gpg.format",
+	"commit.gpgsign",
+	"tag.gpgsign",
+	"gpg.ssh.allowedSignersFile",
+	"gpg.program",
+	"gpg.ssh.program",
+	"gpg.x509.program",
+}
+
+// unsetIsFalse boolean key of identity, which git reads as false if unset
+func unsetIsFalse(key string) bool {
+	key = canonicalConfigKey(key)
+	return key == "commit.gpgsign" || key == "tag.gpgsign"
+}
+
+// gpgProgramKey config key of signing program for the format
+func gpgProgramKey(format string) string {
+	switch format {
+	case SigningSSH:
+		return "gpg.ssh.program"
+	case SigningX509:
+		return "gpg.x509.program"
+	}
+	return "gpg.program"
+}
+
+// GitConfig git config entries of user identity
+func (u *User) GitConfig() []ConfigEntry {
+	var entries []ConfigEntry
+	if u.Name != "" {
+		entries = append(entries, ConfigEntry{Key: "user.name", Value: u.Name})
+	}
+	if u.Email != "" {
+		entries = append(entries, ConfigEntry{Key: "user.email", Value: u.Email})
+	}
+	if u.SigningKey != "" {
+		entries = append(entries, ConfigEntry{Key: "user.signingkey", Value: u.SigningKey})
+	}
+	if u.SigningFormat != "" {
+		entries = append(entries, ConfigEntry{Key: "gpg.format", Value: u.SigningFormat})
+	}
+	// false is written, not to sign with global commit.gpgsign and key of another identity
+	entries = append(entries,
+		ConfigEntry{Key: "commit.gpgsign", Value: strconv.FormatBool(u.GPGSign)},
+		ConfigEntry{Key: "tag.gpgsign", Value: strconv.FormatBool(u.TagGPGSign)},
+	)
+	if u.AllowedSignersFile != "" {
+		entries = append(entries, ConfigEntry{Key: "gpg.ssh.allowedSignersFile", Value: u.AllowedSignersFile})
+	}
+	if u.GPGProgram != "" {
+		entries = append(entries, ConfigEntry{Key: gpgProgramKey(u.SigningFormat), Value: u.GPGProgram})
+	}
+	if u.SSHKey != "" {
+		entries = append(entries, ConfigEntry{Key: sshCommandKey, Value: u.SSHCommand()})
+	}
+	for _, entry := range u.Config {
+		if entry.Value != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// splitConfigKey split `section.subsection.name` key
+func splitConfigKey(key string) (section, subsection, name string) {
+	first := strings.Index(key, ".")
+	last := strings.LastIndex(key, ".")
+	if first < 0 {
+		return "", "", key
+	}
+	if first == last {
+		return key[:first], "", key[last+1:]
+	}
+	return key[:first], key[first+1 : last], key[last+1:]
+}
+
+// sectionHeader `[section]` or `[section "subsection"]`
+func sectionHeader(section, subsection string) string {
+	if subsection == "" {
+		return fmt.Sprintf("[%s]", section)
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return fmt.Sprintf(`[%s "%s"]`, section, r.Replace(subsection))
+}
+
+// quoteConfigValue quote value if needed
+func quoteConfigValue(value string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	escaped := r.Replace(value)
+	if value != strings.TrimSpace(value) || strings.ContainsAny(value, ";#") {
+		return `"` + escaped + `"`
+	}
+	return escaped
+}
+
+// WriteGitConfig write entries in git config file format. entries of same section are grouped
+func WriteGitConfig(w io.Writer, entries []ConfigEntry) error {
+	var headers []string
+	grouped := map[string][]ConfigEntry{}
+	for _, entry := range entries {
+		section, subsection, _ := splitConfigKey(entry.Key)
+		header := sectionHeader(section, subsection)
+		if _, ok := grouped[header]; !ok {
+			headers = append(headers, header)
+		}
+		grouped[header] = append(grouped[header], entry)
+	}
+	for _, header := range headers {
+		if _, err := fmt.Fprintln(w, header); err != nil {
+			return err
+		}
+		for _, entry := range grouped[header] {
+			_, _, name := splitConfigKey(entry.Key)
+			if _, err := fmt.Fprintf(w, "\t%s = %s\n", name, quoteConfigValue(entry.Value)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestConfigMap_json(t *testing.T) {
+	data := `{"http.proxy":"http://proxy:8080","core.sshCommand":"ssh -i ~/.ssh/work","HTTP.proxy":"http://other:8080"}`
+	var m ConfigMap
+	if err := json.Unmarshal([]byte(data), &m); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	want := ConfigMap{
+		{Key: "http.proxy", Value: "http://other:8080"},
+		{Key: "core.sshCommand", Value: "ssh -i ~/.ssh/work"},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("Unmarshal() = %v, want %v", m, want)
+	}
+	if value, ok := m.Get("core.sshcommand"); !ok || value != "ssh -i ~/.ssh/work" {
+		t.Errorf("Get() = %v, %v", value, ok)
+	}
+
+	got, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(got) != `{"http.proxy":"http://other:8080","core.sshCommand":"ssh -i ~/.ssh/work"}` {
+		t.Errorf("Marshal() = %s", got)
+	}
+}
+
+func TestConfigMap_jsonScalar(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    ConfigMap
+		wantErr bool
+	}{
+		{"bool", `{"http.sslVerify":false}`, ConfigMap{{Key: "http.sslVerify", Value: "false"}}, false},
+		{"number", `{"http.postBuffer":524288000,"core.bigFileThreshold":1.5}`, ConfigMap{{Key: "http.postBuffer", Value: "524288000"}, {Key: "core.bigFileThreshold", Value: "1.5"}}, false},
+		{"object", `{"http.proxy":{"url":"http://proxy:8080"}}`, nil, true},
+		{"array", `{"http.proxy":["http://proxy:8080"]}`, nil, true},
+		{"null", `{"http.proxy":null}`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m ConfigMap
+			err := json.Unmarshal([]byte(tt.data), &m)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(m, tt.want) {
+				t.Errorf("Unmarshal() = %v, want %v", m, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConfigEntry(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    ConfigEntry
+		wantErr bool
+	}{
+		{"key value", "core.sshCommand=ssh -i ~/.ssh/id=work", ConfigEntry{"core.sshCommand", "ssh -i ~/.ssh/id=work"}, false},
+		{"subsection", "credential.https://github.com.username=mike", ConfigEntry{"credential.https://github.com.username", "mike"}, false},
+		{"empty value", "http.proxy=", ConfigEntry{"http.proxy", ""}, false},
+		{"no value", "http.proxy", ConfigEntry{}, true},
+		{"no section", "proxy=http://proxy", ConfigEntry{"proxy", "http://proxy"}, true},
+		{"invalid name", "http.1proxy=x", ConfigEntry{"http.1proxy", "x"}, true},
+		{"managed key", "User.Email=mike@example.com", ConfigEntry{"User.Email", "mike@example.com"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseConfigEntry(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseConfigEntry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseConfigEntry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsers_ConfigKeys(t *testing.T) {
+	users := Users{
+		&User{Config: ConfigMap{{Key: "core.sshCommand", Value: "ssh -i ~/.ssh/work"}}},
+		&User{Config: ConfigMap{{Key: "core.sshcommand", Value: "ssh -i ~/.ssh/oss"}, {Key: "http.proxy", Value: "http://proxy:8080"}}},
+	}
+	got := users.ConfigKeys()
+	want := append(append([]string{}, managedConfigKeys...), "core.sshCommand", "http.proxy")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ConfigKeys() = %v, want %v", got, want)
+	}
+
+	users = Users{&User{}, &User{SSHKey: "~/.ssh/id_work"}}
+	got = users.ConfigKeys()
+	want = append(append([]string{}, managedConfigKeys...), "core.sshCommand")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ConfigKeys() = %v, want %v", got, want)
+	}
+}
+
+func TestUser_GitConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		user *User
+		want []ConfigEntry
+	}{
+		{
+			"identity",
+			&User{Name: "Mike", Email: "mike@example.com"},
+			[]ConfigEntry{{"user.name", "Mike"}, {"user.email", "mike@example.com"}, {"commit.gpgsign", "false"}, {"tag.gpgsign", "false"}},
+		},
+		{
+			"openpgp",
+			&User{Name: "Mike", Email: "mike@example.com", SigningKey: "ABCD", GPGSign: true, GPGProgram: "gpg2"},
+			[]ConfigEntry{
+				{"user.name", "Mike"}, {"user.email", "mike@example.com"}, {"user.signingkey", "ABCD"},
+				{"commit.gpgsign", "true"}, {"tag.gpgsign", "false"}, {"gpg.program", "gpg2"},
+			},
+		},
+		{
+			"ssh",
+			&User{
+				Name: "Mike", Email: "mike@example.com", SigningKey: "~/.ssh/id_ed25519.pub",
+				SigningFormat: SigningSSH, GPGSign: true, TagGPGSign: true,
+				AllowedSignersFile: "~/.ssh/allowed_signers", GPGProgram: "ssh-keygen",
+			},
+			[]ConfigEntry{
+				{"user.name", "Mike"}, {"user.email", "mike@example.com"}, {"user.signingkey", "~/.ssh/id_ed25519.pub"},
+				{"gpg.format", "ssh"}, {"commit.gpgsign", "true"}, {"tag.gpgsign", "true"},
+				{"gpg.ssh.allowedSignersFile", "~/.ssh/allowed_signers"}, {"gpg.ssh.program", "ssh-keygen"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.user.GitConfig(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GitConfig() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteGitConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []ConfigEntry
+		want    string
+	}{
+		{
+			"group by section",
+			[]ConfigEntry{
+				{Key: "user.name", Value: "Mike Wazowski"},
+				{Key: "gpg.format", Value: "ssh"},
+				{Key: "user.email", Value: "mike@example.com"},
+			},
+			"[user]\n\tname = Mike Wazowski\n\temail = mike@example.com\n[gpg]\n\tformat = ssh\n",
+		},
+		{
+			"subsection",
+			[]ConfigEntry{
+				{Key: "gpg.ssh.allowedSignersFile", Value: "~/.ssh/allowed_signers"},
+			},
+			"[gpg \"ssh\"]\n\tallowedSignersFile = ~/.ssh/allowed_signers\n",
+		},
+		{
+			"quote value",
+			[]ConfigEntry{
+				{Key: "user.name", Value: " Mike; \"Wazowski\""},
+			},
+			"[user]\n\tname = \" Mike; \\\"Wazowski\\\"\"\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if err := WriteGitConfig(buf, tt.entries); err != nil {
+				t.Errorf("WriteGitConfig() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("WriteGitConfig() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// gitConfigSection section of git config file
+type gitConfigSection struct {
+	// name lower cased section name
+	name       string
+	subsection string
+	// headerEnd end of header line
+	headerEnd int
+}
+
+// gitConfigVariable variable of git config file
+type gitConfigVariable struct {
+	section int
+	name    string
+	value   string
+	// start and end of variable lines. variable following section header on same line starts after it
+	start, end int
+}
+
+// gitConfigFile git config file, editable keeping comments and layout
+type gitConfigFile struct {
+	data      []byte
+	sections  []gitConfigSection
+	variables []gitConfigVariable
+}
+
+// gitConfigEntry key and value of git config. section and name of key are lower cased
+type gitConfigEntry struct {
+	Key   string
+	Value string
+}
+
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// parseGitConfig parse git config file, see git-config(1) CONFIGURATION FILE
+func parseGitConfig(data []byte) (*gitConfigFile, error) {
+	f := &gitConfigFile{data: data}
+	i := 0
+	if bytes.HasPrefix(data, utf8BOM) {
+		i = len(utf8BOM)
+	}
+	line, lineStart, headerLine := 1, i, 0
+	for i < len(data) {
+		c := data[i]
+		switch {
+		case c == '\n':
+			i++
+			line++
+			lineStart = i
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#' || c == ';':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+		case c == '[':
+			section, next, err := parseGitConfigHeader(data, i)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			if end := bytes.IndexByte(data[next:], '\n'); end >= 0 {
+				section.headerEnd = next + end + 1
+			} else {
+				section.headerEnd = len(data)
+			}
+			f.sections = append(f.sections, section)
+			i = next
+			headerLine = line
+		case isAlpha(c):
+			if len(f.sections) == 0 {
+				return nil, fmt.Errorf("line %d: variable outside section", line)
+			}
+			start := lineStart
+			if headerLine == line {
+				start = i
+			}
+			name, value, next, lines, err := parseGitConfigVariable(data, i)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			end := next
+			if end < len(data) && start == lineStart {
+				end++
+			}
+			f.variables = append(f.variables, gitConfigVariable{
+				section: len(f.sections) - 1,
+				name:    name,
+				value:   value,
+				start:   start,
+				end:     end,
+			})
+			i = next
+			line += lines
+		default:
+			return nil, fmt.Errorf("line %d: bad config line", line)
+		}
+	}
+	return f, nil
+}
+
+func parseGitConfigHeader(data []byte, i int) (gitConfigSection, int, error) {
+	var section gitConfigSection
+	start := i + 1
+	for i = start; i < len(data) && (isAlnum(data[i]) || data[i] == '-' || data[i] == '.'); i++ {
+	}
+	section.name = string(data[start:i])
+	if i < len(data) && (data[i] == ' ' || data[i] == '\t') {
+		for i < len(data) && (data[i] == ' ' || data[i] == '\t') {
+			i++
+		}
+		if i >= len(data) || data[i] != '"' {
+			return section, 0, fmt.Errorf("bad section header")
+		}
+		sub := &strings.Builder{}
+		for i++; i < len(data) && data[i] != '"'; i++ {
+			if data[i] == '\n' {
+				return section, 0, fmt.Errorf("bad section header")
+			}
+			if data[i] == '\\' && i+1 < len(data) && data[i+1] != '\n' {
+				i++
+			}
+			sub.WriteByte(data[i])
+		}
+		section.subsection = sub.String()
+		i++
+	} else if dot := strings.IndexByte(section.name, '.'); dot >= 0 {
+		// deprecated [section.subsection] syntax
+		section.name, section.subsection = section.name[:dot], strings.ToLower(section.name[dot+1:])
+	}
+	if section.name == "" || i >= len(data) || data[i] != ']' {
+		return section, 0, fmt.Errorf("bad section header")
+	}
+	section.name = strings.ToLower(section.name)
+	return section, i + 1, nil
+}
+
+// parseGitConfigVariable parse `name = value`. next is end of line, lines is number of continued lines
+func parseGitConfigVariable(data []byte, i int) (name, value string, next, lines int, err error) {
+	start := i
+	for i < len(data) && (isAlnum(data[i]) || data[i] == '-') {
+		i++
+	}
+	name = string(data[start:i])
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t' || data[i] == '\r') {
+		i++
+	}
+	if i == len(data) || data[i] == '\n' || data[i] == '#' || data[i] == ';' {
+		// implicit true without value
+		for i < len(data) && data[i] != '\n' {
+			i++
+		}
+		return name, "", i, 0, nil
+	}
+	if data[i] != '=' {
+		return "", "", 0, 0, fmt.Errorf("bad config line")
+	}
+
+	buf := &strings.Builder{}
+	quote, comment, space := false, false, 0
+	for i++; i < len(data) && data[i] != '\n'; i++ {
+		c := data[i]
+		if comment {
+			continue
+		}
+		if isSpace(c) && !quote {
+			if buf.Len() > 0 {
+				space++
+			}
+			continue
+		}
+		if !quote && (c == '#' || c == ';') {
+			comment = true
+			continue
+		}
+		for ; space > 0; space-- {
+			buf.WriteByte(' ')
+		}
+		switch c {
+		case '\\':
+			i++
+			if i < len(data) && data[i] == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+				i++
+			}
+			if i >= len(data) {
+				return "", "", 0, 0, fmt.Errorf("bad escape")
+			}
+			switch data[i] {
+			case '\n':
+				lines++
+			case 'n':
+				buf.WriteByte('\n')
+			case 't':
+				buf.WriteByte('\t')
+			case 'b':
+				buf.WriteByte('\b')
+			case '\\', '"':
+				buf.WriteByte(data[i])
+			default:
+				return "", "", 0, 0, fmt.Errorf("bad escape")
+			}
+		case '"':
+			quote = !quote
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	if quote {
+		return "", "", 0, 0, fmt.Errorf("missing closing quote")
+	}
+	return name, buf.String(), i, lines, nil
+}
+
+func isAlpha(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isAlnum(c byte) bool {
+	return isAlpha(c) || '0' <= c && c <= '9'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\v' || c == '\f'
+}
+
+// Entries all variables in order
+func (f *gitConfigFile) Entries() []gitConfigEntry {
+	entries := make([]gitConfigEntry, len(f.variables))
+	for i, v := range f.variables {
+		s := f.sections[v.section]
+		key := s.name + "." + v.name
+		if s.subsection != "" {
+			key = s.name + "." + s.subsection + "." + v.name
+		}
+		entries[i] = gitConfigEntry{Key: canonicalConfigKey(key), Value: v.value}
+	}
+	return entries
+}
+
+func (f *gitConfigFile) find(key string) ([]int, error) {
+	section, subsection, name := splitConfigKey(key)
+	if section == "" || name == "" {
+		return nil, fmt.Errorf("invalid key %q", key)
+	}
+	var found []int
+	for i, v := range f.variables {
+		s := f.sections[v.section]
+		if strings.EqualFold(s.name, section) && s.subsection == subsection && strings.EqualFold(v.name, name) {
+			found = append(found, i)
+		}
+	}
+	return found, nil
+}
+
+// Set replace value of key, or add it into last section of key like `git config key value`
+func (f *gitConfigFile) Set(key, value string) error {
+	found, err := f.find(key)
+	if err != nil {
+		return err
+	}
+	section, subsection, name := splitConfigKey(key)
+	line := name + " = " + quoteConfigValue(value)
+
+	switch {
+	case len(found) > 1:
+		return fmt.Errorf("%s has multiple values", key)
+	case len(found) == 1:
+		v := f.variables[found[0]]
+		if v.start == 0 || f.data[v.start-1] == '\n' {
+			line = "\t" + line
+		}
+		if v.end > v.start && f.data[v.end-1] == '\n' {
+			line += "\n"
+		}
+		return f.splice(v.start, v.end, line)
+	}
+
+	pos := -1
+	for i := len(f.sections) - 1; i >= 0 && pos < 0; i-- {
+		if strings.EqualFold(f.sections[i].name, section) && f.sections[i].subsection == subsection {
+			pos = f.sections[i].headerEnd
+			for _, v := range f.variables {
+				if v.section == i && v.end > pos {
+					pos = v.end
+				}
+			}
+		}
+	}
+	if pos < 0 {
+		pos = len(f.data)
+		line = sectionHeader(section, subsection) + "\n\t" + line
+	} else {
+		line = "\t" + line
+	}
+	if pos > 0 && f.data[pos-1] != '\n' {
+		line = "\n" + line
+	}
+	return f.splice(pos, pos, line+"\n")
+}
+
+// Unset remove all values of key like `git config --unset-all key`. ErrKeyNotFound if key is not set
+func (f *gitConfigFile) Unset(key string) error {
+	found, err := f.find(key)
+	if err != nil {
+		return err
+	}
+	for i := len(found) - 1; i >= 0; i-- {
+		v := f.variables[found[i]]
+		f.data = append(f.data[:v.start:v.start], f.data[v.end:]...)
+	}
+	if len(found) == 0 {
+		return ErrKeyNotFound
+	}
+	return f.reparse()
+}
+
+func (f *gitConfigFile) splice(start, end int, text string) error {
+	data := make([]byte, 0, len(f.data)+len(text))
+	data = append(data, f.data[:start]...)
+	data = append(data, text...)
+	f.data = append(data, f.data[end:]...)
+	return f.reparse()
+}
+
+func (f *gitConfigFile) reparse() error {
+	parsed, err := parseGitConfig(f.data)
+	if err != nil {
+		return err
+	}
+	*f = *parsed
+	return nil
+}
+
+// editGitConfigFile edit git config file under config.lock of git, then rename it into file.
+// error is *GitError like failure of `git config`
+func editGitConfigFile(path string, edit func(*gitConfigFile) error) error {
+	return replaceGitConfigFile(path, func(data []byte) ([]byte, error) {
+		f, err := parseGitConfig(data)
+		if err != nil {
+			return nil, err
+		}
+		if err := edit(f); err != nil {
+			return nil, err
+		}
+		return f.data, nil
+	})
+}
+
+// replaceGitConfigFile replace content of git config file under config.lock of git like editGitConfigFile,
+// e.g. block of ~/.gitconfig
+func replaceGitConfigFile(path string, replace func([]byte) ([]byte, error)) error {
+	if err := replaceLockedFile(path, replace); err != nil {
+		return configFileError(path, err)
+	}
+	return nil
+}
+
+func replaceLockedFile(path string, replace func([]byte) ([]byte, error)) error {
+	lockPath := path + ".lock"
+	lock, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		if os.IsExist(err) {
+			return ErrLocked
+		}
+		return err
+	}
+	locked := true
+	defer func() {
+		if locked {
+			lock.Close()
+			os.Remove(lockPath)
+		}
+	}()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	updated, err := replace(data)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(updated, data) {
+		return nil
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		if err := lock.Chmod(info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if _, err := lock.Write(updated); err != nil {
+		return err
+	}
+	if err := lock.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(lockPath, path); err != nil {
+		return err
+	}
+	locked = false
+	return nil
+}
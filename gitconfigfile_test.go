@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseGitConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []gitConfigEntry
+		wantErr bool
+	}{
+		{
+			"sections",
+			"[User]\n\tName = Mike\n[remote \"Origin\"] url = a\n[branch.Main]\n\tremote\n",
+			[]gitConfigEntry{{"user.name", "Mike"}, {"remote.Origin.url", "a"}, {"branch.main.remote", ""}},
+			false,
+		},
+		{
+			"values",
+			"\xef\xbb\xbf[a]\r\n\tb = \" x \"y # c\r\n\tc = 1\\\n2\n\td = a  \t b ; c\n",
+			[]gitConfigEntry{{"a.b", " x y"}, {"a.c", "12"}, {"a.d", "a    b"}},
+			false,
+		},
+		{"outside section", "a = b\n", nil, true},
+		{"bad header", "[a \"b]\n", nil, true},
+		{"missing quote", "[a]\n\tb = \"c\n", nil, true},
+		{"bad escape", "[a]\n\tb = \\x\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := parseGitConfig([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseGitConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(f.Entries(), tt.want) {
+				t.Errorf("parseGitConfig() = %v, want %v", f.Entries(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_gitConfigFile_Set(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		key   string
+		value string
+		want  string
+	}{
+		{"replace", "[user]\n\tname = a # c\n\temail = b\n", "user.name", "Mike", "[user]\n\tname = Mike\n\temail = b\n"},
+		{"after header", "[user] name = a\n", "user.name", "Mike", "[user] name = Mike\n"},
+		{"append to section", "[user]\n\tname = a\n[core]\n", "user.email", "m@example.com", "[user]\n\tname = a\n\temail = m@example.com\n[core]\n"},
+		{"new section", "[user]\n\tname = a", "gpg.ssh.allowedSignersFile", "~/a b", "[user]\n\tname = a\n[gpg \"ssh\"]\n\tallowedSignersFile = ~/a b\n"},
+		{"quote", "", "core.sshCommand", "ssh -i \"k\" #", "[core]\n\tsshCommand = \"ssh -i \\\"k\\\" #\"\n"},
+		{"unset", "[user]\n\tname = a\n\tname = b\n\temail = c\n", "user.name", "", "[user]\n\temail = c\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _ := parseGitConfig([]byte(tt.data))
+			var err error
+			if tt.value == "" {
+				err = f.Unset(tt.key)
+			} else {
+				err = f.Set(tt.key, tt.value)
+			}
+			if err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+			if string(f.data) != tt.want {
+				t.Errorf("Set() = %q, want %q", f.data, tt.want)
+			}
+		})
+	}
+
+	f, _ := parseGitConfig([]byte("[a]\n\tb = 1\n\tb = 2\n"))
+	if err := f.Set("a.b", "3"); err == nil {
+		t.Errorf("Set() no error with multiple values")
+	}
+}
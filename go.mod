@@ -3,8 +3,10 @@ module github.com/tsuty/git-user
 go 1.13
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/jessevdk/go-flags v1.4.0
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/ryanuber/go-glob v1.0.0
 	github.com/valyala/fasttemplate v1.1.0
+	gopkg.in/yaml.v3 v3.0.1
 )
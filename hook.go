@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// hook script
+const (
+	hookMarker        = "# git-user hook. installed by `git-user hook install`"
+	hookChainedSuffix = ".git-user-chained"
+)
+
+// HookScript shell script of hook. it checks git-user, then runs chained hook and repository hook
+func HookScript(name, executable string, fix, global bool) string {
+	check := shellQuote(executable) + " hook check"
+	if fix {
+		check += " --fix"
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, "#!/bin/sh")
+	fmt.Fprintln(buf, hookMarker)
+	fmt.Fprintf(buf, "%s || exit $?\n", check)
+	fmt.Fprintf(buf, "chained=\"$(dirname \"$0\")/%s%s\"\n", name, hookChainedSuffix)
+	fmt.Fprintln(buf, "if [ -x \"$chained\" ]; then")
+	fmt.Fprintln(buf, "\t\"$chained\" \"$@\" || exit $?")
+	fmt.Fprintln(buf, "fi")
+	if global {
+		fmt.Fprintf(buf, "repository=\"$(git rev-parse --git-dir)/hooks/%s\"\n", name)
+		fmt.Fprintln(buf, "if [ -x \"$repository\" ]; then")
+		fmt.Fprintln(buf, "\t\"$repository\" \"$@\" || exit $?")
+		fmt.Fprintln(buf, "fi")
+	}
+	return buf.String()
+}
+
+// InstallHook write hook into dir. existing hook not installed by git-user is kept as chained hook
+func InstallHook(dir, name, script string) (chained bool, err error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return false, err
+	}
+
+	path := filepath.Join(dir, name)
+	current, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if err == nil && !strings.Contains(string(current), hookMarker) {
+		if err := os.Rename(path, path+hookChainedSuffix); err != nil {
+			return false, err
+		}
+		chained = true
+	}
+
+	return chained, ioutil.WriteFile(path, []byte(script), 0755)
+}
+
+// shellQuote quote string for sh
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHookScript(t *testing.T) {
+	tests := []struct {
+		name       string
+		fix        bool
+		global     bool
+		contains   []string
+		notContain []string
+	}{
+		{
+			"local",
+			false,
+			false,
+			[]string{hookMarker, "'/usr/bin/git-user' hook check || exit $?", "pre-commit" + hookChainedSuffix},
+			[]string{"--fix", "git rev-parse --git-dir"},
+		},
+		{
+			"global with fix",
+			true,
+			true,
+			[]string{"'/usr/bin/git-user' hook check --fix || exit $?", "$(git rev-parse --git-dir)/hooks/pre-commit"},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := HookScript("pre-commit", "/usr/bin/git-user", tt.fix, tt.global)
+			for _, s := range tt.contains {
+				if !strings.Contains(got, s) {
+					t.Errorf("HookScript() = %v, want contain %v", got, s)
+				}
+			}
+			for _, s := range tt.notContain {
+				if strings.Contains(got, s) {
+					t.Errorf("HookScript() = %v, want not contain %v", got, s)
+				}
+			}
+		})
+	}
+}
+
+func TestInstallHook(t *testing.T) {
+	tests := []struct {
+		name        string
+		existing    string
+		wantChained bool
+	}{
+		{"no hook", "", false},
+		{"other hook", "#!/bin/sh\necho other\n", true},
+		{"installed hook", "#!/bin/sh\n" + hookMarker + "\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, _ := ioutil.TempDir(os.TempDir(), "git-user_test")
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "pre-commit")
+			if tt.existing != "" {
+				ioutil.WriteFile(path, []byte(tt.existing), 0755)
+			}
+
+			chained, err := InstallHook(dir, "pre-commit", "script")
+			if err != nil {
+				t.Fatalf("InstallHook() error = %v", err)
+			}
+			if chained != tt.wantChained {
+				t.Errorf("InstallHook() chained = %v, want %v", chained, tt.wantChained)
+			}
+			if got, _ := ioutil.ReadFile(path); string(got) != "script" {
+				t.Errorf("InstallHook() wrote %v", string(got))
+			}
+			if got, _ := ioutil.ReadFile(path + hookChainedSuffix); tt.wantChained && string(got) != tt.existing {
+				t.Errorf("InstallHook() chained hook %v, want %v", string(got), tt.existing)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// managed block of global git config
+const (
+	includeIfBegin = "# BEGIN git-user managed block. do not edit, run `git-user export-includeif`"
+	includeIfEnd   = "# END git-user managed block"
+	// identityFileHeader first line of identity config files. only files having it are removed as stale
+	identityFileHeader = "# written by git-user. do not edit, run `git-user export-includeif`"
+)
+
+// IncludeIfConditions conditions of `[includeIf "..."]` for user rule.
+// git wildmatch `*` does not cross `/`, so trailing `*` segment is translated into `**`
+func (u *User) IncludeIfConditions() ([]string, error) {
+	switch u.MatchType() {
+	case MatchGlob:
+		repo, ok := ParseRepoURL(u.URL)
+		if !ok || repo.Path == "" {
+			return nil, fmt.Errorf("%s is not a repository url pattern", u.URL)
+		}
+		var conditions []string
+		for _, path := range includeIfPaths(repo.Path) {
+			scp := []string{path}
+			if path == "**" {
+				scp = []string{"*", "*/**"}
+			}
+			conditions = append(conditions,
+				fmt.Sprintf("hasconfig:remote.*.url:http*://%s/%s", repo.Host, path),
+				fmt.Sprintf("hasconfig:remote.*.url:ssh://*@%s/%s", repo.Host, path),
+				fmt.Sprintf("hasconfig:remote.*.url:git://%s/%s", repo.Host, path),
+			)
+			for _, p := range scp {
+				conditions = append(conditions, fmt.Sprintf("hasconfig:remote.*.url:*@%s:%s", repo.Host, p))
+			}
+		}
+		return conditions, nil
+	case MatchPath:
+		path := strings.TrimRight(u.URL, "/")
+		if i := strings.LastIndex(path, "/"); i >= 0 && strings.Trim(path[i+1:], "*") == "" {
+			path = path[:i]
+		}
+		return []string{"gitdir:" + path + "/"}, nil
+	}
+	return nil, fmt.Errorf("%s rule can not be expressed with includeIf", u.MatchType())
+}
+
+func includeIfPaths(path string) []string {
+	segments := strings.Split(path, "/")
+	last := segments[len(segments)-1]
+	if last == "*" {
+		segments[len(segments)-1] = "**"
+		return []string{strings.Join(segments, "/")}
+	}
+	if strings.HasSuffix(last, "*") {
+		return []string{path}
+	}
+	return []string{path, path + ".git"}
+}
+
+// SortForInclude sort users in ascending precedence, because later include wins in git config
+func SortForInclude(us Users) Users {
+	sorted := make(Users, len(us))
+	copy(sorted, us)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+		if ap, bp := a.MatchType() == MatchPath, b.MatchType() == MatchPath; ap != bp {
+			return ap
+		}
+		c, _ := a.Compare(b)
+		return c > 0
+	})
+	return sorted
+}
+
+// IncludeIfBlock managed block text. includes is pairs of condition and path
+func IncludeIfBlock(includes [][2]string) string {
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, includeIfBegin)
+	for _, include := range includes {
+		fmt.Fprintln(buf, sectionHeader("includeIf", include[0]))
+		fmt.Fprintf(buf, "\tpath = %s\n", quoteConfigValue(include[1]))
+	}
+	fmt.Fprintln(buf, includeIfEnd)
+	return buf.String()
+}
+
+// ReplaceManagedBlock replace managed block in git config text, or append it
+func ReplaceManagedBlock(config, block string) string {
+	begin := strings.Index(config, includeIfBegin)
+	end := strings.Index(config, includeIfEnd)
+	if begin >= 0 && end > begin {
+		end += len(includeIfEnd)
+		if end < len(config) && config[end] == '\n' {
+			end++
+		}
+		return config[:begin] + block + config[end:]
+	}
+	if config != "" && !strings.HasSuffix(config, "\n") {
+		config += "\n"
+	}
+	return config + block
+}
+
+// IdentityFile content of identity config file with header
+func IdentityFile(u *User) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, identityFileHeader)
+	if err := WriteGitConfig(buf, u.GitConfig()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// IsIdentityFile file is identity config file written by git-user
+func IsIdentityFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	line, _ := bufio.NewReader(f).ReadString('\n')
+	return strings.TrimRight(line, "\r\n") == identityFileHeader
+}
+
+// IdentityFileName file name of identity config. same identity share a file
+func IdentityFileName(u *User) string {
+	buf := &bytes.Buffer{}
+	WriteGitConfig(buf, u.GitConfig())
+	hash := fmt.Sprintf("%x", sha1.Sum(buf.Bytes()))[0:userHashSie]
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r <= ' ' {
+			return '_'
+		}
+		return r
+	}, u.Email)
+	return fmt.Sprintf("%s-%s.gitconfig", name, hash)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUser_IncludeIfConditions(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    *User
+		want    []string
+		wantErr bool
+	}{
+		{
+			"owner wildcard",
+			&User{URL: "git@github.com:acme/*"},
+			[]string{
+				"hasconfig:remote.*.url:http*://github.com/acme/**",
+				"hasconfig:remote.*.url:ssh://*@github.com/acme/**",
+				"hasconfig:remote.*.url:git://github.com/acme/**",
+				"hasconfig:remote.*.url:*@github.com:acme/**",
+			},
+			false,
+		},
+		{
+			"exact repository",
+			&User{URL: "github.com/acme/repo"},
+			[]string{
+				"hasconfig:remote.*.url:http*://github.com/acme/repo",
+				"hasconfig:remote.*.url:ssh://*@github.com/acme/repo",
+				"hasconfig:remote.*.url:git://github.com/acme/repo",
+				"hasconfig:remote.*.url:*@github.com:acme/repo",
+				"hasconfig:remote.*.url:http*://github.com/acme/repo.git",
+				"hasconfig:remote.*.url:ssh://*@github.com/acme/repo.git",
+				"hasconfig:remote.*.url:git://github.com/acme/repo.git",
+				"hasconfig:remote.*.url:*@github.com:acme/repo.git",
+			},
+			false,
+		},
+		{
+			"path",
+			&User{Type: MatchPath, URL: "~/work/*"},
+			[]string{"gitdir:~/work/"},
+			false,
+		},
+		{
+			"exact path",
+			&User{Type: MatchPath, URL: "/src/acme"},
+			[]string{"gitdir:/src/acme/"},
+			false,
+		},
+		{
+			"regex",
+			&User{Type: MatchRegex, URL: "^github.com/"},
+			nil,
+			true,
+		},
+		{
+			"not repository url",
+			&User{URL: "*"},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.user.IncludeIfConditions()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("IncludeIfConditions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("IncludeIfConditions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortForInclude(t *testing.T) {
+	us := Users{
+		&User{URL: "git@github.com:acme/*", Name: "Company"},
+		&User{URL: "~/oss/*", Type: MatchPath, Name: "OSS", Priority: 1},
+		&User{URL: "git@github.com:*", Name: "GitHub"},
+		&User{URL: "~/work/*", Type: MatchPath, Name: "Work"},
+	}
+	want := []string{"Work", "GitHub", "Company", "OSS"}
+
+	var got []string
+	for _, u := range SortForInclude(us) {
+		got = append(got, u.Name)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SortForInclude() = %v, want %v", got, want)
+	}
+}
+
+func TestReplaceManagedBlock(t *testing.T) {
+	block := IncludeIfBlock([][2]string{{"gitdir:~/work/", "/home/tsuty/work.gitconfig"}})
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{
+			"empty",
+			"",
+			block,
+		},
+		{
+			"append",
+			"[core]\n\teditor = vi",
+			"[core]\n\teditor = vi\n" + block,
+		},
+		{
+			"replace",
+			"[core]\n\teditor = vi\n" + includeIfBegin + "\n[includeIf \"gitdir:~/old/\"]\n\tpath = old\n" + includeIfEnd + "\n[alias]\n\tst = status\n",
+			"[core]\n\teditor = vi\n" + block + "[alias]\n\tst = status\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ReplaceManagedBlock(tt.config, block)
+			if got != tt.want {
+				t.Errorf("ReplaceManagedBlock() = %q, want %q", got, tt.want)
+			}
+			if again := ReplaceManagedBlock(got, block); again != got {
+				t.Errorf("ReplaceManagedBlock() is not idempotent %q", again)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// tryLockFile flock lock file without blocking
+func tryLockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, errLocked
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// unlockFile lock is released by closing. lock file is kept not to race with other processes opening it
+func unlockFile(f *os.File, path string) error {
+	return f.Close()
+}
+
+// lockTimeoutError flock is released when the process exits, so lock is held by running git-user
+func lockTimeoutError(path string) error {
+	return fmt.Errorf("%s is locked by another running git-user", path)
+}
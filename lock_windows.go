@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// tryLockFile create lock file exclusively, which is removed on unlock
+func tryLockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+	if os.IsExist(err) {
+		return nil, errLocked
+	}
+	return f, err
+}
+
+// unlockFile close and remove lock file
+func unlockFile(f *os.File, path string) error {
+	f.Close()
+	return os.Remove(path)
+}
+
+// lockTimeoutError lock file is left if git-user is killed
+func lockTimeoutError(path string) error {
+	return fmt.Errorf("%s is locked by another git-user, remove it if not running", path)
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/jessevdk/go-flags"
 )
@@ -13,14 +15,31 @@ func main() {
 	parser.Name = "git-user"
 	_, e := parser.Parse()
 	if e != nil {
-		return
+		var flagsErr *flags.Error
+		if errors.As(e, &flagsErr) && flagsErr.Type == flags.ErrHelp {
+			return
+		}
+		os.Exit(ExitUsage)
 	}
 	if parser.Active == nil {
 		parser.WriteHelp(os.Stdout)
-		return
+		os.Exit(ExitUsage)
 	}
-	if err := context.Execute(parser.Active.Name); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		return
+	if err := context.Execute(commandName(parser.Active)); err != nil {
+		var exitErr *ExitError
+		if !errors.As(err, &exitErr) || !exitErr.Reported() {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(exitStatus(err))
 	}
 }
+
+// commandName active command name including subcommands. e.g. `hook install`
+func commandName(command *flags.Command) string {
+	names := []string{command.Name}
+	for command.Active != nil {
+		command = command.Active
+		names = append(names, command.Name)
+	}
+	return strings.Join(names, " ")
+}
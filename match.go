@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/ryanuber/go-glob"
+)
+
+// match type of user rule
+const (
+	MatchGlob       = "glob"
+	MatchRegex      = "regex"
+	MatchStructured = "structured"
+	MatchPath       = "path"
+)
+
+// MatchType return match type of rule. glob if not specified
+func (u *User) MatchType() string {
+	if u.Type == "" {
+		return MatchGlob
+	}
+	return u.Type
+}
+
+// Rule is display form of rule
+func (u *User) Rule() string {
+	switch u.MatchType() {
+	case MatchRegex:
+		return "regex:" + u.URL
+	case MatchPath:
+		return "path:" + u.URL
+	case MatchStructured:
+		var fields []string
+		for _, f := range []struct{ key, value string }{{"host", u.Host}, {"owner", u.Owner}, {"repo", u.Repo}} {
+			if f.value != "" {
+				fields = append(fields, f.key+"="+f.value)
+			}
+		}
+		return strings.Join(fields, " ")
+	}
+	return u.URL
+}
+
+// SameRule is rule identical
+func (u *User) SameRule(o *User) bool {
+	return u.MatchType() == o.MatchType() && u.Rule() == o.Rule()
+}
+
+// Valid validate rule
+func (u *User) Valid() error {
+	switch u.MatchType() {
+	case MatchGlob:
+		if u.URL == "" {
+			return errors.New("required url of glob rule")
+		}
+	case MatchRegex:
+		if u.URL == "" {
+			return errors.New("required url of regex rule")
+		}
+		if _, err := regexp.Compile(u.URL); err != nil {
+			return fmt.Errorf("invalid regex rule %s: %v", u.URL, err)
+		}
+	case MatchPath:
+		if !strings.HasPrefix(u.URL, "/") && !strings.HasPrefix(u.URL, "~") {
+			return fmt.Errorf("path rule must be absolute or start with ~: %s", u.URL)
+		}
+	case MatchStructured:
+		if u.Host == "" && u.Owner == "" && u.Repo == "" {
+			return errors.New("required host, owner or repo of structured rule")
+		}
+	default:
+		return fmt.Errorf("unknown match type %s", u.Type)
+	}
+	for _, entry := range u.Config {
+		if err := ValidConfigKey(entry.Key); err != nil {
+			return err
+		}
+	}
+	if _, ok := u.Config.Get(sshCommandKey); ok && u.SSHKey != "" {
+		return fmt.Errorf("ssh key conflicts with %s config", sshCommandKey)
+	}
+	return nil
+}
+
+// MatchURL match rule with raw URL, or with normalized URL
+func (u *User) MatchURL(url string) bool {
+	switch u.MatchType() {
+	case MatchPath:
+		return false
+	case MatchRegex:
+		re, err := regexp.Compile(u.URL)
+		if err != nil {
+			return false
+		}
+		return re.MatchString(url) || re.MatchString(NormalizeURL(url))
+	case MatchStructured:
+		repo, ok := ParseRepoURL(url)
+		if !ok {
+			return false
+		}
+		name := repo.Path
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			name = name[i+1:]
+		}
+		return matchField(u.Host, repo.Host) &&
+			matchField(u.Owner, repo.Owner) &&
+			matchField(u.Repo, name)
+	}
+
+	if glob.Glob(u.URL, url) {
+		return true
+	}
+	repo, ok := ParseRepoURL(url)
+	if !ok {
+		return false
+	}
+	return glob.Glob(NormalizeURL(u.URL), repo.String())
+}
+
+// MatchPath match path rule with work tree path
+func (u *User) MatchPath(path string) bool {
+	if u.MatchType() != MatchPath {
+		return false
+	}
+	pattern, err := homedir.Expand(u.URL)
+	if err != nil {
+		return false
+	}
+	return glob.Glob(filepath.ToSlash(pattern), filepath.ToSlash(path))
+}
+
+// matchField glob match. leading `!` negates, empty pattern matches anything
+func matchField(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	if strings.HasPrefix(pattern, "!") {
+		return !glob.Glob(pattern[1:], value)
+	}
+	return glob.Glob(pattern, value)
+}
+
+// Specificity count literal characters, literal segments and wildcards of rule
+func (u *User) Specificity() (literals, segments, wildcards int) {
+	switch u.MatchType() {
+	case MatchRegex:
+		re, err := syntax.Parse(u.URL, syntax.Perl)
+		if err != nil {
+			return 0, 0, 0
+		}
+		return countLiterals(re), 0, 0
+	case MatchStructured:
+		for _, field := range []string{u.Host, u.Owner, u.Repo} {
+			if field == "" {
+				wildcards++
+				continue
+			}
+			if strings.HasPrefix(field, "!") {
+				continue
+			}
+			literals += len(field) - strings.Count(field, "*")
+			wildcards += strings.Count(field, "*")
+			if !strings.Contains(field, "*") {
+				segments++
+			}
+		}
+		return literals, segments, wildcards
+	}
+
+	pattern := NormalizeURL(u.URL)
+	if u.MatchType() == MatchPath {
+		pattern, _ = homedir.Expand(u.URL)
+	}
+	literals = len(pattern) - strings.Count(pattern, "*")
+	wildcards = strings.Count(pattern, "*")
+	for _, segment := range strings.FieldsFunc(pattern, isURLSeparator) {
+		if !strings.Contains(segment, "*") {
+			segments++
+		}
+	}
+	return literals, segments, wildcards
+}
+
+func countLiterals(re *syntax.Regexp) int {
+	if re.Op == syntax.OpLiteral {
+		return len(re.Rune)
+	}
+	if re.Op == syntax.OpAlternate || re.Op == syntax.OpStar || re.Op == syntax.OpQuest {
+		return 0
+	}
+	n := 0
+	for _, sub := range re.Sub {
+		n += countLiterals(sub)
+	}
+	return n
+}
+
+func isURLSeparator(r rune) bool {
+	return r == '/' || r == ':'
+}
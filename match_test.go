@@ -0,0 +1,115 @@
+package main
+
+import "testing"
+
+func TestUser_MatchURL(t *testing.T) {
+	tests := []struct {
+		name string
+		user *User
+		url  string
+		want bool
+	}{
+		{
+			"regex match raw url",
+			&User{Type: MatchRegex, URL: `^git@github\.com:acme/.*-internal(\.git)?$`},
+			"git@github.com:acme/tools-internal.git",
+			true,
+		},
+		{
+			"regex match normalized url",
+			&User{Type: MatchRegex, URL: `^github\.com/acme/.*-internal$`},
+			"https://github.com/acme/tools-internal.git",
+			true,
+		},
+		{
+			"regex not match",
+			&User{Type: MatchRegex, URL: `-internal$`},
+			"git@github.com:acme/tools.git",
+			false,
+		},
+		{
+			"structured match",
+			&User{Type: MatchStructured, Host: "github.com", Owner: "acme"},
+			"https://github.com/acme/tools.git",
+			true,
+		},
+		{
+			"structured negated owner",
+			&User{Type: MatchStructured, Host: "github.com", Owner: "!acme"},
+			"git@github.com:acme/tools.git",
+			false,
+		},
+		{
+			"structured negated owner match others",
+			&User{Type: MatchStructured, Host: "github.com", Owner: "!acme"},
+			"git@github.com:tsuty/tools.git",
+			true,
+		},
+		{
+			"structured repo glob",
+			&User{Type: MatchStructured, Repo: "*-internal"},
+			"ssh://git@gitlab.com/acme/group/tools-internal.git",
+			true,
+		},
+		{
+			"structured local path",
+			&User{Type: MatchStructured, Repo: "*"},
+			"/srv/git/tools.git",
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.user.MatchURL(tt.url); got != tt.want {
+				t.Errorf("MatchURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUser_Valid(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    *User
+		wantErr bool
+	}{
+		{"glob", &User{URL: "git@github.com:*"}, false},
+		{"glob without url", &User{}, true},
+		{"regex", &User{Type: MatchRegex, URL: "^github.com/acme/"}, false},
+		{"invalid regex", &User{Type: MatchRegex, URL: "^github.com/(acme"}, true},
+		{"structured", &User{Type: MatchStructured, Owner: "!acme"}, false},
+		{"structured without field", &User{Type: MatchStructured}, true},
+		{"path", &User{Type: MatchPath, URL: "~/work/*"}, false},
+		{"invalid config key", &User{URL: "git@github.com:*", Config: ConfigMap{{Key: "user.email", Value: "mike@example.com"}}}, true},
+		{"ssh key with ssh command", &User{URL: "git@github.com:*", SSHKey: "~/.ssh/id_work", Config: ConfigMap{{Key: "core.sshcommand", Value: "ssh"}}}, true},
+		{"relative path", &User{Type: MatchPath, URL: "work/*"}, true},
+		{"unknown type", &User{Type: "fuzzy", URL: "*"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.user.Valid(); (err != nil) != tt.wantErr {
+				t.Errorf("Valid() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUser_MatchPath(t *testing.T) {
+	tests := []struct {
+		name string
+		user *User
+		path string
+		want bool
+	}{
+		{"match", &User{Type: MatchPath, URL: "/home/tsuty/work/**"}, "/home/tsuty/work/acme/repo", true},
+		{"not match", &User{Type: MatchPath, URL: "/home/tsuty/work/**"}, "/home/tsuty/oss/repo", false},
+		{"not path rule", &User{URL: "/home/tsuty/work/**"}, "/home/tsuty/work/repo", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.user.MatchPath(tt.path); got != tt.want {
+				t.Errorf("MatchPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
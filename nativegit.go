@@ -0,0 +1,630 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// maximum depth of include.path like git
+const maxIncludeDepth = 10
+
+// gitRepository location of git repository
+type gitRepository struct {
+	// WorkTree top level of work tree. empty if bare or inside git directory
+	WorkTree string
+	// GitDir .git directory, or .git/worktrees/<name> of linked worktree
+	GitDir string
+	// CommonDir git directory having config, shared by worktrees
+	CommonDir string
+	// inGitDir current directory is inside git directory
+	inGitDir bool
+}
+
+// NativeGit git backend reading and writing git config in process. other operations run git command
+type NativeGit struct {
+	Git
+
+	repo       *gitRepository
+	repoErr    error
+	discovered bool
+	entries    []gitConfigEntry
+	loaded     bool
+	files      map[string][]gitConfigEntry
+}
+
+// discoverGitRepository find git directory from dir to root like git, following gitfile of worktrees and submodules
+func discoverGitRepository(dir string) (*gitRepository, error) {
+	start, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if real, err := filepath.EvalSymlinks(start); err == nil {
+		start = real
+	}
+
+	if gitDir := os.Getenv("GIT_DIR"); gitDir != "" {
+		repo := &gitRepository{GitDir: absPath(gitDir, start), WorkTree: start}
+		if workTree := os.Getenv("GIT_WORK_TREE"); workTree != "" {
+			repo.WorkTree = absPath(workTree, start)
+		}
+		return repo.init()
+	}
+
+	for d := start; ; d = filepath.Dir(d) {
+		dotGit := filepath.Join(d, ".git")
+		if info, err := os.Stat(dotGit); err == nil {
+			if info.IsDir() && isGitDir(dotGit) {
+				return (&gitRepository{GitDir: dotGit, WorkTree: d, inGitDir: isSubPath(dotGit, start)}).init()
+			}
+			if !info.IsDir() {
+				gitDir, err := readGitFile(dotGit)
+				if err != nil {
+					return nil, err
+				}
+				return (&gitRepository{GitDir: gitDir, WorkTree: d}).init()
+			}
+		}
+		if isGitDir(d) {
+			return (&gitRepository{GitDir: d, inGitDir: true}).init()
+		}
+		if filepath.Dir(d) == d {
+			return nil, ErrNotRepository
+		}
+	}
+}
+
+// init find common directory, and bare repository
+func (r *gitRepository) init() (*gitRepository, error) {
+	r.CommonDir = r.GitDir
+	if data, err := ioutil.ReadFile(filepath.Join(r.GitDir, "commondir")); err == nil {
+		r.CommonDir = absPath(strings.TrimSpace(string(data)), r.GitDir)
+	}
+	if r.WorkTree != "" && os.Getenv("GIT_WORK_TREE") == "" {
+		if f, err := readGitConfigFile(filepath.Join(r.CommonDir, "config")); err == nil {
+			for _, e := range f.Entries() {
+				if e.Key == "core.bare" && parseGitBool(e.Value) {
+					r.WorkTree = ""
+				}
+			}
+		}
+	}
+	return r, nil
+}
+
+// readGitFile git directory of `gitdir: <path>` file
+func readGitFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	line := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(line, "gitdir: ") {
+		return "", fmt.Errorf("invalid gitfile format: %s", path)
+	}
+	gitDir := absPath(strings.TrimPrefix(line, "gitdir: "), filepath.Dir(path))
+	if !isGitDir(gitDir) {
+		return "", fmt.Errorf("not a git repository: %s", gitDir)
+	}
+	return gitDir, nil
+}
+
+func isGitDir(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, "HEAD")); err != nil {
+		return false
+	}
+	for _, name := range []string{"commondir", "objects"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func absPath(path, base string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(base, path)
+}
+
+// isSubPath path is dir or under it
+func isSubPath(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// parseGitBool boolean value of git config
+func parseGitBool(value string) bool {
+	switch strings.ToLower(value) {
+	case "", "true", "yes", "on", "1":
+		return true
+	}
+	return false
+}
+
+func readGitConfigFile(path string) (*gitConfigFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, configFileError(path, err)
+	}
+	f, err := parseGitConfig(data)
+	if err != nil {
+		return nil, configFileError(path, err)
+	}
+	return f, nil
+}
+
+// configFileError error of reading or writing git config file, typed as failure of `git config --file`
+func configFileError(path string, err error) error {
+	return &GitError{Args: []string{"config", "--file", path}, Err: err}
+}
+
+func (g *NativeGit) repository() (*gitRepository, error) {
+	if !g.discovered {
+		dir := g.Dir
+		if dir == "" {
+			dir = "."
+		}
+		g.repo, g.repoErr = discoverGitRepository(dir)
+		g.discovered = true
+	}
+	return g.repo, g.repoErr
+}
+
+// systemConfigFiles system config file. git prefix is unknown, so /etc/gitconfig unless GIT_CONFIG_SYSTEM
+func systemConfigFiles() []string {
+	if v, ok := os.LookupEnv("GIT_CONFIG_NOSYSTEM"); ok && parseGitBool(v) {
+		return nil
+	}
+	if path := os.Getenv("GIT_CONFIG_SYSTEM"); path != "" {
+		return []string{path}
+	}
+	return []string{"/etc/gitconfig"}
+}
+
+// globalConfigFiles $XDG_CONFIG_HOME/git/config and ~/.gitconfig, or GIT_CONFIG_GLOBAL
+func globalConfigFiles() []string {
+	if path := os.Getenv("GIT_CONFIG_GLOBAL"); path != "" {
+		return []string{path}
+	}
+	var files []string
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" {
+		xdg = "~/.config"
+	}
+	if path, err := homedir.Expand(filepath.Join(xdg, "git", "config")); err == nil {
+		files = append(files, path)
+	}
+	if path, err := homedir.Expand("~/.gitconfig"); err == nil {
+		files = append(files, path)
+	}
+	return files
+}
+
+// globalConfigFile file written by `git config --global`
+func globalConfigFile() string {
+	files := globalConfigFiles()
+	path := files[len(files)-1]
+	if len(files) == 2 {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if _, err := os.Stat(files[0]); err == nil {
+				return files[0]
+			}
+		}
+	}
+	return path
+}
+
+// localConfigFiles config of repository, and config.worktree if enabled
+func (g *NativeGit) localConfigFiles() ([]string, error) {
+	repo, err := g.repository()
+	if err != nil {
+		return nil, err
+	}
+	files := []string{filepath.Join(repo.CommonDir, "config")}
+	entries, err := g.fileEntries(files[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.Key == "extensions.worktreeconfig" && parseGitBool(e.Value) {
+			files = append(files, filepath.Join(repo.GitDir, "config.worktree"))
+		}
+	}
+	return files, nil
+}
+
+// fileEntries entries of file without includes, like `git config --file`. missing file has no entries
+func (g *NativeGit) fileEntries(path string) ([]gitConfigEntry, error) {
+	if entries, ok := g.files[path]; ok {
+		return entries, nil
+	}
+	var entries []gitConfigEntry
+	f, err := readGitConfigFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if f != nil {
+		entries = f.Entries()
+	}
+	if g.files == nil {
+		g.files = map[string][]gitConfigEntry{}
+	}
+	g.files[path] = entries
+	return entries, nil
+}
+
+// allEntries entries of system, global, local and worktree config with includes, and of environment
+func (g *NativeGit) allEntries() ([]gitConfigEntry, error) {
+	if g.loaded {
+		return g.entries, nil
+	}
+	repo, err := g.repository()
+	if err != nil && !errors.Is(err, ErrNotRepository) {
+		return nil, err
+	}
+	loader := &gitConfigLoader{repo: repo}
+	entries, err := loader.load(g)
+	if err == nil && loader.hasconfig {
+		// hasconfig:remote.*.url: is evaluated with remote urls of config without such includes
+		for _, e := range entries {
+			if strings.HasPrefix(e.Key, "remote.") && strings.HasSuffix(e.Key, ".url") {
+				loader.remoteURLs = append(loader.remoteURLs, e.Value)
+			}
+		}
+		entries, err = loader.load(g)
+	}
+	if err != nil {
+		return nil, err
+	}
+	g.entries, g.loaded = entries, true
+	return entries, nil
+}
+
+func (g *NativeGit) reset() {
+	g.entries, g.loaded, g.files = nil, false, nil
+}
+
+// lastValue value of key, or ErrKeyNotFound
+func lastValue(entries []gitConfigEntry, key string) (string, error) {
+	key = canonicalConfigKey(key)
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Key == key {
+			return entries[i].Value, nil
+		}
+	}
+	return "", ErrKeyNotFound
+}
+
+// IsInsideWorkTree like `git rev-parse --is-inside-work-tree`
+func (g *NativeGit) IsInsideWorkTree() (bool, error) {
+	repo, err := g.repository()
+	if errors.Is(err, ErrNotRepository) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return repo.WorkTree != "" && !repo.inGitDir, nil
+}
+
+// GetTopLevel like `git rev-parse --show-toplevel`
+func (g *NativeGit) GetTopLevel() (string, error) {
+	repo, err := g.repository()
+	if err != nil {
+		return "", err
+	}
+	return repo.WorkTree, nil
+}
+
+// GetRemotes like `git config --get-regexp ^remote\..*\.url$`
+func (g *NativeGit) GetRemotes() (Remotes, error) {
+	entries, err := g.allEntries()
+	if err != nil {
+		return nil, err
+	}
+	var remotes Remotes
+	for _, e := range entries {
+		if strings.HasPrefix(e.Key, "remote.") && strings.HasSuffix(e.Key, ".url") && len(e.Key) > len("remote..url") {
+			remotes = append(remotes, &Remote{Name: e.Key[len("remote.") : len(e.Key)-len(".url")], URL: e.Value})
+		}
+	}
+	return remotes, nil
+}
+
+// GetConfig like `git config --get $key`
+func (g *NativeGit) GetConfig(key string) (string, error) {
+	entries, err := g.allEntries()
+	if err != nil {
+		return "", err
+	}
+	return lastValue(entries, key)
+}
+
+// GetGlobalConfig like `git config --global --get $key`
+func (g *NativeGit) GetGlobalConfig(key string) (string, error) {
+	var entries []gitConfigEntry
+	for _, path := range globalConfigFiles() {
+		found, err := g.fileEntries(path)
+		if err != nil {
+			return "", err
+		}
+		entries = append(entries, found...)
+	}
+	return lastValue(entries, key)
+}
+
+// SetGlobalConfig like `git config --global $key $value`
+func (g *NativeGit) SetGlobalConfig(key, value string) error {
+	defer g.reset()
+	return editGitConfigFile(globalConfigFile(), func(f *gitConfigFile) error {
+		return f.Set(key, value)
+	})
+}
+
+// GetLocalConfig like `git config --local --get $key`
+func (g *NativeGit) GetLocalConfig(key string) (string, error) {
+	files, err := g.localConfigFiles()
+	if err != nil {
+		return "", err
+	}
+	entries, err := g.fileEntries(files[0])
+	if err != nil {
+		return "", err
+	}
+	return lastValue(entries, key)
+}
+
+// SetLocalConfig like `git config --local $key $value`
+func (g *NativeGit) SetLocalConfig(key, value string) error {
+	files, err := g.localConfigFiles()
+	if err != nil {
+		return err
+	}
+	defer g.reset()
+	return editGitConfigFile(files[0], func(f *gitConfigFile) error {
+		return f.Set(key, value)
+	})
+}
+
+// UnsetLocalConfig like `git config --local --unset-all $key`
+func (g *NativeGit) UnsetLocalConfig(key string) error {
+	files, err := g.localConfigFiles()
+	if err != nil {
+		return err
+	}
+	defer g.reset()
+	return editGitConfigFile(files[0], func(f *gitConfigFile) error {
+		return f.Unset(key)
+	})
+}
+
+// gitConfigLoader load config files with include.path and includeIf.<condition>.path
+type gitConfigLoader struct {
+	repo *gitRepository
+	// remoteURLs urls for hasconfig:remote.*.url: condition
+	remoteURLs []string
+	// hasconfig hasconfig condition is found
+	hasconfig bool
+}
+
+func (l *gitConfigLoader) load(g *NativeGit) ([]gitConfigEntry, error) {
+	files := append(systemConfigFiles(), globalConfigFiles()...)
+	if l.repo != nil {
+		local, err := g.localConfigFiles()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, local...)
+	}
+
+	var entries []gitConfigEntry
+	for _, path := range files {
+		found, err := l.readFile(path, 0)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, found...)
+	}
+	found, err := environmentConfig()
+	if err != nil {
+		return nil, err
+	}
+	return append(entries, found...), nil
+}
+
+func (l *gitConfigLoader) readFile(path string, depth int) ([]gitConfigEntry, error) {
+	f, err := readGitConfigFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []gitConfigEntry
+	for _, e := range f.Entries() {
+		entries = append(entries, e)
+		include, ok := l.includePath(e, path)
+		if !ok {
+			continue
+		}
+		if depth >= maxIncludeDepth {
+			return nil, fmt.Errorf("exceeded maximum include depth (%d) including %s from %s", maxIncludeDepth, include, path)
+		}
+		found, err := l.readFile(include, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, found...)
+	}
+	return entries, nil
+}
+
+// includePath path of include.path, or of includeIf.<condition>.path if condition is true
+func (l *gitConfigLoader) includePath(e gitConfigEntry, from string) (string, bool) {
+	if e.Value == "" {
+		return "", false
+	}
+	if e.Key != "include.path" {
+		if !strings.HasPrefix(e.Key, "includeif.") || !strings.HasSuffix(e.Key, ".path") {
+			return "", false
+		}
+		condition := e.Key[len("includeif.") : len(e.Key)-len(".path")]
+		if !l.condition(condition, from) {
+			return "", false
+		}
+	}
+	path, err := homedir.Expand(e.Value)
+	if err != nil {
+		return "", false
+	}
+	return absPath(path, filepath.Dir(from)), true
+}
+
+// condition evaluate gitdir:, gitdir/i:, onbranch: and hasconfig:remote.*.url: condition
+func (l *gitConfigLoader) condition(condition, from string) bool {
+	switch {
+	case strings.HasPrefix(condition, "gitdir:"):
+		return l.matchGitDir(strings.TrimPrefix(condition, "gitdir:"), from, false)
+	case strings.HasPrefix(condition, "gitdir/i:"):
+		return l.matchGitDir(strings.TrimPrefix(condition, "gitdir/i:"), from, true)
+	case strings.HasPrefix(condition, "onbranch:"):
+		if l.repo == nil {
+			return false
+		}
+		data, err := ioutil.ReadFile(filepath.Join(l.repo.GitDir, "HEAD"))
+		if err != nil {
+			return false
+		}
+		head := strings.TrimSpace(string(data))
+		if !strings.HasPrefix(head, "ref: refs/heads/") {
+			return false
+		}
+		pattern := strings.TrimPrefix(condition, "onbranch:")
+		if strings.HasSuffix(pattern, "/") {
+			pattern += "**"
+		}
+		return wildmatch(pattern, strings.TrimPrefix(head, "ref: refs/heads/"), false)
+	case strings.HasPrefix(condition, "hasconfig:remote.*.url:"):
+		l.hasconfig = true
+		pattern := strings.TrimPrefix(condition, "hasconfig:remote.*.url:")
+		for _, url := range l.remoteURLs {
+			if wildmatch(pattern, url, false) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchGitDir match git directory with gitdir pattern. `~/` is home, `./` is directory of including file,
+// relative pattern matches at any depth, and trailing `/` matches everything inside
+func (l *gitConfigLoader) matchGitDir(pattern, from string, fold bool) bool {
+	if l.repo == nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(pattern, "~/"):
+		home, err := homedir.Dir()
+		if err != nil {
+			return false
+		}
+		pattern = filepath.ToSlash(home) + pattern[1:]
+	case strings.HasPrefix(pattern, "./"):
+		pattern = filepath.ToSlash(filepath.Dir(from)) + pattern[1:]
+	case !strings.HasPrefix(pattern, "/"):
+		pattern = "**/" + pattern
+	}
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	dirs := []string{l.repo.GitDir}
+	if real, err := filepath.EvalSymlinks(l.repo.GitDir); err == nil && real != l.repo.GitDir {
+		dirs = append(dirs, real)
+	}
+	for _, dir := range dirs {
+		if wildmatch(pattern, filepath.ToSlash(dir), fold) {
+			return true
+		}
+	}
+	return false
+}
+
+// environmentConfig config of GIT_CONFIG_PARAMETERS (`git -c`) and GIT_CONFIG_COUNT
+func environmentConfig() ([]gitConfigEntry, error) {
+	var entries []gitConfigEntry
+	if params := os.Getenv("GIT_CONFIG_PARAMETERS"); params != "" {
+		words, err := splitShellQuoted(params)
+		if err != nil {
+			return nil, fmt.Errorf("GIT_CONFIG_PARAMETERS: %v", err)
+		}
+		for _, word := range words {
+			kv := strings.SplitN(word, "=", 2)
+			if len(kv) == 1 {
+				kv = append(kv, "")
+			}
+			entries = append(entries, newGitConfigEntry(kv[0], kv[1]))
+		}
+	}
+
+	count, err := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	if err != nil {
+		return entries, nil
+	}
+	for i := 0; i < count; i++ {
+		key, ok := os.LookupEnv(fmt.Sprintf("GIT_CONFIG_KEY_%d", i))
+		if !ok {
+			return nil, fmt.Errorf("missing config key GIT_CONFIG_KEY_%d", i)
+		}
+		entries = append(entries, newGitConfigEntry(key, os.Getenv(fmt.Sprintf("GIT_CONFIG_VALUE_%d", i))))
+	}
+	return entries, nil
+}
+
+func newGitConfigEntry(key, value string) gitConfigEntry {
+	return gitConfigEntry{Key: canonicalConfigKey(key), Value: value}
+}
+
+// splitShellQuoted split words quoted by git sq_quote, e.g. `'user.name'='Mike'`. adjacent quoted parts are joined
+func splitShellQuoted(s string) ([]string, error) {
+	var words []string
+	word := &strings.Builder{}
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == ' ':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("missing closing quote")
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '\\' && i+1 < len(s):
+			i++
+			word.WriteByte(s[i])
+			inWord = true
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// isolatedHome set HOME to temporary directory without system config
+func isolatedHome(t *testing.T) (string, func()) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "git-user_test")
+	dir, _ = filepath.EvalSymlinks(dir)
+	env := map[string]string{"HOME": dir, "XDG_CONFIG_HOME": "", "GIT_CONFIG_NOSYSTEM": "1"}
+	saved := map[string]string{}
+	for key, value := range env {
+		saved[key] = os.Getenv(key)
+		os.Setenv(key, value)
+	}
+	homedir.DisableCache = true
+	return dir, func() {
+		for key, value := range saved {
+			os.Setenv(key, value)
+		}
+		homedir.DisableCache = false
+		os.RemoveAll(dir)
+	}
+}
+
+func gitCommand(t *testing.T, dir string, args ...string) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v %s", args, err, out)
+	}
+}
+
+func TestNativeGit_GetConfig(t *testing.T) {
+	home, cleanup := isolatedHome(t)
+	defer cleanup()
+
+	repo := filepath.Join(home, "work", "repo")
+	gitCommand(t, home, "init", "-q", repo)
+	gitCommand(t, repo, "remote", "add", "origin", "git@github.com:acme/repo.git")
+	gitCommand(t, repo, "remote", "add", "upstream", "https://github.com/upstream/repo.git")
+	ioutil.WriteFile(filepath.Join(home, ".gitconfig"), []byte(`[user]
+	name = Global Name ; comment
+	email = global@example.com
+[include]
+	path = .gitconfig.d/include
+[includeIf "gitdir:~/work/"]
+	path = .gitconfig.d/work
+[includeIf "gitdir/i:REPO/"]
+	path = .gitconfig.d/nocase
+[includeIf "onbranch:feature/"]
+	path = .gitconfig.d/feature
+[includeIf "hasconfig:remote.*.url:*@github.com:acme/**"]
+	path = .gitconfig.d/acme
+[includeIf "gitdir:~/other/"]
+	path = .gitconfig.d/other
+`), 0644)
+	os.MkdirAll(filepath.Join(home, ".gitconfig.d"), 0755)
+	files := map[string]string{
+		"include": "[test]\n\tinclude = yes\n\tquoted = \"  a;b#c  \" # comment\n\tescaped = tab\\tnewline\\n\\\"q\\\" back\\\\\n\tcontinued = one \\\n  two\n\timplicit\n",
+		"work":    "[user]\n\temail = work@example.com\n[Test \"Sub.Section\"]\n\tKey = work\n",
+		"nocase":  "[test]\n\tnocase = yes\n",
+		"feature": "[test]\n\tbranch = feature\n",
+		"acme":    "[user]\n\tsigningkey = ACME\n",
+		"other":   "[user]\n\tname = Other\n",
+	}
+	for name, content := range files {
+		ioutil.WriteFile(filepath.Join(home, ".gitconfig.d", name), []byte(content), 0644)
+	}
+	gitCommand(t, repo, "config", "--local", "test.local", "local")
+	gitCommand(t, repo, "config", "--local", "test.multi", "1")
+	gitCommand(t, repo, "config", "--local", "--add", "test.multi", "2")
+	gitCommand(t, repo, "commit", "-q", "--allow-empty", "-m", "init")
+	gitCommand(t, repo, "worktree", "add", "-q", "-b", "feature/x", filepath.Join(home, "feature"))
+
+	keys := []string{
+		"user.name", "user.email", "user.signingkey", "test.include", "test.quoted", "test.escaped",
+		"test.continued", "test.implicit", "Test.Sub.Section.key", "test.nocase", "test.branch",
+		"test.local", "test.multi", "remote.origin.url", "missing.key",
+	}
+	for _, dir := range []string{repo, filepath.Join(repo, "sub"), filepath.Join(home, "feature"), home} {
+		os.MkdirAll(dir, 0755)
+		t.Run(dir, func(t *testing.T) {
+			native, git := &NativeGit{Git: Git{Dir: dir}}, &Git{Dir: dir}
+			compare := func(name string, got, want interface{}, err, wantErr error) {
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("%s = %v, want %v", name, got, want)
+				}
+				for _, kind := range []error{ErrKeyNotFound, ErrNotRepository} {
+					if errors.Is(err, kind) != errors.Is(wantErr, kind) || (err == nil) != (wantErr == nil) {
+						t.Errorf("%s error = %v, want %v", name, err, wantErr)
+					}
+				}
+			}
+			inside, err := native.IsInsideWorkTree()
+			wantInside, wantErr := git.IsInsideWorkTree()
+			compare("IsInsideWorkTree()", inside, wantInside, err, wantErr)
+			top, err := native.GetTopLevel()
+			wantTop, wantErr := git.GetTopLevel()
+			compare("GetTopLevel()", top, wantTop, err, wantErr)
+			remotes, err := native.GetRemotes()
+			wantRemotes, wantErr := git.GetRemotes()
+			compare("GetRemotes()", remotes, wantRemotes, err, wantErr)
+			for _, key := range keys {
+				for name, get := range map[string][2]func(string) (string, error){
+					"GetConfig":       {native.GetConfig, git.GetConfig},
+					"GetLocalConfig":  {native.GetLocalConfig, git.GetLocalConfig},
+					"GetGlobalConfig": {native.GetGlobalConfig, git.GetGlobalConfig},
+				} {
+					got, err := get[0](key)
+					want, wantErr := get[1](key)
+					compare(name+"("+key+")", got, want, err, wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestNativeGit_SetLocalConfig(t *testing.T) {
+	home, cleanup := isolatedHome(t)
+	defer cleanup()
+
+	repo := filepath.Join(home, "repo")
+	gitCommand(t, home, "init", "-q", repo)
+	config := filepath.Join(repo, ".git", "config")
+	f, _ := os.OpenFile(config, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString("# keep comment\n[user] name = Old\n[gpg \"ssh\"]\n\tallowedSignersFile = old\n\tallowedSignersFile = old2\n[core]\n\tsshCommand = ssh\n")
+	f.Close()
+
+	native := &NativeGit{Git: Git{Dir: repo}}
+	tests := []struct {
+		key   string
+		value string
+	}{
+		{"user.name", "New Name"},
+		{"user.email", "new@example.com"},
+		{"gpg.ssh.allowedSignersFile", ""},
+		{"core.sshCommand", `ssh -i "~/.ssh/id rsa" # key`},
+		{"commit.gpgsign", "true"},
+		{"credential.https://example.com.username", " spaced\tvalue "},
+	}
+	for _, tt := range tests {
+		var err error
+		if tt.value != "" {
+			err = native.SetLocalConfig(tt.key, tt.value)
+		} else {
+			err = native.UnsetLocalConfig(tt.key)
+		}
+		if err != nil {
+			t.Fatalf("SetLocalConfig(%s) error = %v", tt.key, err)
+		}
+	}
+
+	git := &Git{Dir: repo}
+	for _, tt := range tests {
+		if got, _ := git.GetLocalConfig(tt.key); got != tt.value {
+			t.Errorf("git config --get %s = %q, want %q", tt.key, got, tt.value)
+		}
+		if got, _ := native.GetLocalConfig(tt.key); got != tt.value {
+			t.Errorf("GetLocalConfig(%s) = %q, want %q", tt.key, got, tt.value)
+		}
+	}
+	if got, _ := git.GetLocalConfig("core.bare"); got != "false" {
+		t.Errorf("git config --get core.bare = %q, want false", got)
+	}
+	data, _ := ioutil.ReadFile(config)
+	if !reflect.DeepEqual(filepath.Join(repo, ".git", "config.lock"), config+".lock") || len(data) == 0 {
+		t.Errorf("SetLocalConfig() config = %s", data)
+	}
+	if _, err := os.Stat(config + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("SetLocalConfig() left lock file")
+	}
+}
@@ -4,15 +4,29 @@ import "errors"
 
 // Option command option
 type Option struct {
-	Show   ShowOption   `command:"show" description:"Show git-user"`
-	Set    SetOption    `command:"set" description:"Set git-user"`
-	Delete DeleteOption `command:"delete" description:"Delete git-user"`
-	Local  LocalOption  `command:"local" description:"Show local git user.*"`
-	List   ListOption   `command:"list" description:"Show all git-user"`
-	Sync   SyncOption   `command:"sync" description:"Sync to local git"`
-	Print  PrintOption  `command:"print" description:"Print with sync"`
+	Show    ShowOption    `command:"show" description:"Show git-user"`
+	Set     SetOption     `command:"set" description:"Set git-user"`
+	Delete  DeleteOption  `command:"delete" description:"Delete git-user"`
+	Local   LocalOption   `command:"local" description:"Show local git user.*"`
+	List    ListOption    `command:"list" description:"Show all git-user"`
+	Sync    SyncOption    `command:"sync" description:"Sync to local git"`
+	Print   PrintOption   `command:"print" description:"Print with sync"`
+	Explain ExplainOption `command:"explain" description:"Explain which git-user matches current repository"`
 
-	Config string `long:"config" value-name:"file" description:"configuration file name" default:"~/git-user.json" env:"GIT_USER_CONFIG"`
+	ExportIncludeIf ExportIncludeIfOption `command:"export-includeif" description:"Export git-user as includeIf of global git config"`
+	Hook            HookOption            `command:"hook" description:"Git hooks rejecting commits with wrong git user"`
+	Audit           AuditOption           `command:"audit" description:"Audit commits authored with wrong git user"`
+	Doctor          DoctorOption          `command:"doctor" description:"Validate all git-user rules and signing keys"`
+	Profile         ProfileOption         `command:"profile" description:"Manage named identity profiles referenced by rules"`
+	ConfigCommand   ConfigOption          `command:"config" description:"Show configuration files"`
+	Import          ImportOption          `command:"import" description:"Import rules and profiles from shared file"`
+	Export          ExportOption          `command:"export" description:"Export rules and profiles into shared file"`
+
+	Config     string   `long:"config" value-name:"file" description:"configuration file name, json, yaml or toml by extension (default: $XDG_CONFIG_HOME/git-user/config, or ~/git-user.json)" env:"GIT_USER_CONFIG"`
+	Remotes    []string `long:"remote" value-name:"name" description:"remote name in priority order, repeatable (default: settings.remotes of config file, or upstream, origin)" env:"GIT_USER_REMOTES" env-delim:","`
+	GitBackend string   `long:"git-backend" value-name:"backend" choice:"native" choice:"exec" default:"native" description:"read and write git config in process (native) or by git command (exec)" env:"GIT_USER_GIT_BACKEND"`
+	Strict     bool     `long:"strict" description:"fail when nothing matched, for CI and hooks" env:"GIT_USER_STRICT"`
+	Output     string   `long:"output" value-name:"format" choice:"text" choice:"json" choice:"yaml" choice:"tsv" default:"text" description:"output format of show, list, local, set, delete and sync. messages are printed into stderr unless text" env:"GIT_USER_OUTPUT"`
 }
 
 // ShowOption show command option
@@ -22,8 +36,139 @@ type ShowOption struct {
 
 // SetOption set command option
 type SetOption struct {
-	URL  string  `long:"url" value-name:"url" short:"u" description:"Repository url (default: current repository url)"`
-	Args SetArgs `positional-args:"yes"`
+	URL      string  `long:"url" value-name:"url" short:"u" description:"Repository url (default: current repository url)"`
+	Regex    bool    `long:"regex" short:"r" description:"Match url option as regular expression"`
+	Host     string  `long:"host" value-name:"pattern" description:"Match host glob, leading ! negates"`
+	Owner    string  `long:"owner" value-name:"pattern" description:"Match owner glob, leading ! negates"`
+	Repo     string  `long:"repo" value-name:"pattern" description:"Match repository name glob, leading ! negates"`
+	Path     string  `long:"path" value-name:"pattern" description:"Match work tree path glob (e.g. ~/work/*)"`
+	Priority int     `long:"priority" short:"p" value-name:"n" description:"Rule priority, higher wins over specificity (default: 0)"`
+	Profile  string  `long:"profile" short:"P" value-name:"name" description:"Use identity of profile instead of name and email arguments"`
+	Force    bool    `long:"force" description:"Save without checking signing key and ssh key exist"`
+	Args     SetArgs `positional-args:"yes"`
+
+	IdentityOption
+}
+
+// IdentityOption identity option of set and profile command
+type IdentityOption struct {
+	SSHKey string   `long:"ssh-key" value-name:"file" description:"SSH private key of identity, used by core.sshCommand"`
+	Config []string `long:"git-config" short:"c" value-name:"key=value" description:"Extra git config of identity, repeatable (e.g. core.sshCommand=\"ssh -i ~/.ssh/work\")"`
+
+	SigningOption `group:"Signing Options"`
+}
+
+// Valid validate identity options with signing key
+func (o IdentityOption) Valid(signingKey string) error {
+	if _, err := o.ConfigMap(); err != nil {
+		return err
+	}
+	return o.SigningOption.Valid(signingKey)
+}
+
+// Empty is no identity option given
+func (o IdentityOption) Empty() bool {
+	return o.SSHKey == "" && len(o.Config) == 0 && o.SigningOption == SigningOption{}
+}
+
+// ConfigMap parse extra git config option
+func (o IdentityOption) ConfigMap() (ConfigMap, error) {
+	var m ConfigMap
+	for _, s := range o.Config {
+		entry, err := ParseConfigEntry(s)
+		if err != nil {
+			return nil, err
+		}
+		m.Set(entry.Key, entry.Value)
+	}
+	return m, nil
+}
+
+// Identity build identity from options
+func (o IdentityOption) Identity(name, email, signingKey string) Identity {
+	id := Identity{
+		Name:               name,
+		Email:              email,
+		SigningKey:         signingKey,
+		SigningFormat:      o.SigningFormat,
+		GPGSign:            o.GPGSign,
+		TagGPGSign:         o.TagGPGSign,
+		AllowedSignersFile: o.AllowedSignersFile,
+		GPGProgram:         o.GPGProgram,
+		SSHKey:             o.SSHKey,
+	}
+	id.Config, _ = o.ConfigMap()
+	return id
+}
+
+// SigningOption commit signing profile option
+type SigningOption struct {
+	SigningFormat      string `long:"signing-format" value-name:"format" description:"gpg.format" choice:"openpgp" choice:"ssh" choice:"x509"`
+	GPGSign            bool   `long:"gpgsign" description:"Sign all commits (commit.gpgsign)"`
+	TagGPGSign         bool   `long:"tag-gpgsign" description:"Sign all tags (tag.gpgsign)"`
+	AllowedSignersFile string `long:"allowed-signers" value-name:"file" description:"gpg.ssh.allowedSignersFile"`
+	GPGProgram         string `long:"gpg-program" value-name:"program" description:"gpg.program (gpg.ssh.program, gpg.x509.program by format)"`
+}
+
+// Valid validate signing options with signing key
+func (o SigningOption) Valid(signingKey string) error {
+	if (o.GPGSign || o.TagGPGSign) && signingKey == "" {
+		return errors.New("required signingkey argument to sign")
+	}
+	if o.AllowedSignersFile != "" && o.SigningFormat != SigningSSH {
+		return errors.New("allowed signers requires ssh signing format")
+	}
+	return nil
+}
+
+// Structured is structured rule by host, owner or repo
+func (o SetOption) Structured() bool {
+	return o.Host != "" || o.Owner != "" || o.Repo != ""
+}
+
+// Valid validate set command rule options
+func (o SetOption) Valid() error {
+	if o.Structured() && (o.URL != "" || o.Regex) {
+		return errors.New("host, owner and repo can not be used with url or regex")
+	}
+	if o.Path != "" && (o.URL != "" || o.Regex || o.Structured()) {
+		return errors.New("path can not be used with url, regex, host, owner or repo")
+	}
+	if o.Regex && o.URL == "" {
+		return errors.New("required url option with regex")
+	}
+	if o.Profile != "" {
+		if o.Args != (SetArgs{}) || !o.IdentityOption.Empty() {
+			return errors.New("identity arguments and options can not be used with profile. `git-user profile edit`")
+		}
+		return nil
+	}
+	return o.IdentityOption.Valid(o.Args.SigningKey)
+}
+
+// User build user rule from options
+func (o SetOption) User(url string) *User {
+	user := &User{
+		URL:      url,
+		Profile:  o.Profile,
+		Priority: o.Priority,
+	}
+	if o.Profile == "" {
+		user.SetIdentity(o.Identity(o.Args.Name, o.Args.Email, o.Args.SigningKey))
+	}
+	if o.Regex {
+		user.Type = MatchRegex
+	}
+	if o.Path != "" {
+		user.Type = MatchPath
+	}
+	if o.Structured() {
+		user.Type = MatchStructured
+		user.Host = o.Host
+		user.Owner = o.Owner
+		user.Repo = o.Repo
+	}
+	return user
 }
 
 // SetArgs set command args
@@ -64,9 +209,129 @@ type ListOption struct {
 	printOption
 }
 
+// ExplainOption explain command option
+type ExplainOption struct{}
+
+// ExportIncludeIfOption export-includeif command option
+type ExportIncludeIfOption struct {
+	Dir       string `long:"dir" value-name:"dir" description:"Directory of identity config files" default:"~/.git-user/includeif"`
+	GitConfig string `long:"gitconfig" value-name:"file" description:"Global git config file" default:"~/.gitconfig"`
+}
+
+// HookOption hook command option
+type HookOption struct {
+	Install HookInstallOption `command:"install" description:"Install git hooks into current repository"`
+	Check   HookCheckOption   `command:"check" description:"Check git user with git-user (run by hooks)"`
+}
+
+// HookInstallOption hook install command option
+type HookInstallOption struct {
+	Global  bool `long:"global" short:"g" description:"Install into global core.hooksPath"`
+	PrePush bool `long:"pre-push" description:"Install pre-push hook too"`
+	Fix     bool `long:"fix" description:"Sync git user before rejecting"`
+}
+
+// HookCheckOption hook check command option
+type HookCheckOption struct {
+	Fix bool `long:"fix" description:"Sync git user before rejecting"`
+}
+
+// AuditOption audit command option
+type AuditOption struct {
+	Range string `long:"range" value-name:"range" description:"Revision range (default: @{upstream}..HEAD, or commits not on any remote)"`
+	Fix   bool   `long:"fix" description:"Rewrite author and committer of unpushed commits"`
+}
+
+// ProfileOption profile command option
+type ProfileOption struct {
+	Add    ProfileAddOption    `command:"add" description:"Add profile"`
+	Edit   ProfileEditOption   `command:"edit" description:"Replace identity of profile, used by all its rules"`
+	Remove ProfileRemoveOption `command:"rm" description:"Remove profile not referenced by rules"`
+	List   ProfileListOption   `command:"list" description:"Show all profiles"`
+}
+
+// ProfileAddOption profile add command option
+type ProfileAddOption struct {
+	Force bool        `long:"force" description:"Save without checking signing key and ssh key exist"`
+	Args  ProfileArgs `positional-args:"yes"`
+
+	IdentityOption
+}
+
+// ProfileEditOption profile edit command option
+type ProfileEditOption struct {
+	Rename string      `long:"rename" value-name:"name" description:"Rename profile"`
+	Force  bool        `long:"force" description:"Save without checking signing key and ssh key exist"`
+	Args   ProfileArgs `positional-args:"yes"`
+
+	IdentityOption
+}
+
+// ProfileArgs profile add and edit command args
+type ProfileArgs struct {
+	Profile    string `positional-arg-name:"profile" description:"profile name (required)"`
+	Name       string `positional-arg-name:"name" description:"name (required)"`
+	Email      string `positional-arg-name:"email" description:"email address (required)"`
+	SigningKey string `positional-arg-name:"signingkey" description:"signing key (optional)"`
+}
+
+// Valid validate profile command args
+func (as ProfileArgs) Valid() error {
+	if as.Profile == "" {
+		return errors.New("required profile argument")
+	}
+	return SetArgs{Name: as.Name, Email: as.Email, SigningKey: as.SigningKey}.Valid()
+}
+
+// ProfileRemoveOption profile rm command option
+type ProfileRemoveOption struct {
+	Args ProfileRemoveArgs `positional-args:"yes" required:"yes"`
+}
+
+// ProfileRemoveArgs profile rm command args
+type ProfileRemoveArgs struct {
+	Profile string `positional-arg-name:"profile"`
+}
+
+// ProfileListOption profile list command option
+type ProfileListOption struct{}
+
+// ConfigOption config command option
+type ConfigOption struct {
+	Path ConfigPathOption `command:"path" description:"Show configuration files in load order"`
+}
+
+// ConfigPathOption config path command option
+type ConfigPathOption struct{}
+
+// ImportOption import command option
+type ImportOption struct {
+	Mode   string     `long:"mode" short:"m" description:"merge keeps local rules and profiles on conflict, replace overwrites them" choice:"merge" choice:"replace" default:"merge"`
+	DryRun bool       `long:"dry-run" short:"n" description:"Report without saving"`
+	Args   ImportArgs `positional-args:"yes" required:"yes"`
+}
+
+// ImportArgs import command args
+type ImportArgs struct {
+	Path string `positional-arg-name:"file-or-path" description:"shared file, or directory having .git-user.json, .yaml, .yml or .toml"`
+}
+
+// ExportOption export command option
+type ExportOption struct {
+	Filter string `long:"filter" value-name:"glob" description:"Export rules matched with glob (e.g. 'git@github.com:acme/*')"`
+	File   string `long:"file" short:"f" value-name:"file" description:"Write into file, format by extension (default: stdout)"`
+	Format string `long:"format" description:"Format of stdout" choice:"json" choice:"yaml" choice:"toml" default:"json"`
+}
+
+// DoctorOption doctor command option
+type DoctorOption struct{}
+
 // SyncOption sync command option
 type SyncOption struct {
-	Quiet bool `long:"quiet" short:"q" description:"Hide any message"`
+	Quiet     bool   `long:"quiet" short:"q" description:"Hide any message"`
+	Recursive string `long:"recursive" short:"r" value-name:"dir" description:"Sync all work trees under directory"`
+	Jobs      int    `long:"jobs" short:"j" value-name:"n" description:"Number of concurrent jobs with recursive" default:"4"`
+	DryRun    bool   `long:"dry-run" short:"n" description:"Show changes without writing"`
 }
 
 // PrintOption print command option
@@ -1,6 +1,11 @@
 package main
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jessevdk/go-flags"
+)
 
 func TestSetArgs_Valid(t *testing.T) {
 	type fields struct {
@@ -120,3 +125,74 @@ func Test_printOption_printFlag(t *testing.T) {
 		})
 	}
 }
+
+func TestSetOption_Valid(t *testing.T) {
+	tests := []struct {
+		name    string
+		option  SetOption
+		wantErr bool
+	}{
+		{"url", SetOption{URL: "git@github.com:*"}, false},
+		{"regex", SetOption{URL: "^github.com/", Regex: true}, false},
+		{"regex without url", SetOption{Regex: true}, true},
+		{"structured", SetOption{Host: "github.com", Owner: "!acme"}, false},
+		{"structured with url", SetOption{URL: "git@github.com:*", Owner: "acme"}, true},
+		{"structured with regex", SetOption{Regex: true, Repo: "*-internal"}, true},
+		{"path", SetOption{Path: "~/work/*"}, false},
+		{"path with url", SetOption{Path: "~/work/*", URL: "git@github.com:*"}, true},
+		{"gpgsign", SetOption{URL: "git@github.com:*", Args: SetArgs{SigningKey: "ABCD"}, IdentityOption: IdentityOption{SigningOption: SigningOption{GPGSign: true}}}, false},
+		{"gpgsign without signingkey", SetOption{URL: "git@github.com:*", IdentityOption: IdentityOption{SigningOption: SigningOption{GPGSign: true}}}, true},
+		{"tag-gpgsign without signingkey", SetOption{URL: "git@github.com:*", IdentityOption: IdentityOption{SigningOption: SigningOption{TagGPGSign: true}}}, true},
+		{"allowed signers", SetOption{URL: "git@github.com:*", IdentityOption: IdentityOption{SigningOption: SigningOption{SigningFormat: SigningSSH, AllowedSignersFile: "~/.ssh/allowed_signers"}}}, false},
+		{"config", SetOption{URL: "git@github.com:*", IdentityOption: IdentityOption{Config: []string{"http.proxy=http://proxy:8080"}}}, false},
+		{"invalid config", SetOption{URL: "git@github.com:*", IdentityOption: IdentityOption{Config: []string{"http.proxy"}}}, true},
+		{"profile", SetOption{URL: "git@github.com:*", Profile: "work"}, false},
+		{"profile with name", SetOption{URL: "git@github.com:*", Profile: "work", Args: SetArgs{Name: "Mike"}}, true},
+		{"profile with ssh key", SetOption{URL: "git@github.com:*", Profile: "work", IdentityOption: IdentityOption{SSHKey: "~/.ssh/id_work"}}, true},
+		{"allowed signers without ssh", SetOption{URL: "git@github.com:*", IdentityOption: IdentityOption{SigningOption: SigningOption{AllowedSignersFile: "~/.ssh/allowed_signers"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.option.Valid(); (err != nil) != tt.wantErr {
+				t.Errorf("Valid() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSetOption_gitConfig(t *testing.T) {
+	var option Option
+	args := []string{"set", "-u", "git@github.com:*", "--config", "git-user.toml", "--git-config", "http.proxy=http://proxy:8080", "-c", "commit.template=~/t", "Mike", "mike@example.com"}
+	if _, err := flags.NewParser(&option, flags.None).ParseArgs(args); err != nil {
+		t.Fatal(err)
+	}
+	if option.Config != "git-user.toml" {
+		t.Errorf("Config = %v, want git-user.toml", option.Config)
+	}
+	want := []string{"http.proxy=http://proxy:8080", "commit.template=~/t"}
+	if !reflect.DeepEqual(option.Set.Config, want) {
+		t.Errorf("Set.Config = %v, want %v", option.Set.Config, want)
+	}
+}
+
+func TestOption_choice(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{"native", []string{"--git-backend", "native", "list"}, false},
+		{"exec", []string{"--git-backend", "exec", "list"}, false},
+		{"unknown backend", []string{"--git-backend", "libgit2", "list"}, true},
+		{"tsv", []string{"--output", "tsv", "list"}, false},
+		{"unknown output", []string{"--output", "csv", "list"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var option Option
+			if _, err := flags.NewParser(&option, flags.None).ParseArgs(tt.args); (err != nil) != tt.wantErr {
+				t.Errorf("ParseArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
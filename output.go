@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// output format of printer
+const (
+	OutputText = "text"
+	OutputTSV  = "tsv"
+)
+
+// UserRecord user printed as record
+type UserRecord struct {
+	Hash          string
+	URL           string
+	Profile       string `json:",omitempty"`
+	Name          string
+	Email         string
+	SigningKey    string       `json:",omitempty"`
+	SigningFormat string       `json:",omitempty"`
+	SSHKey        string       `json:",omitempty"`
+	Config        ConfigMap    `json:",omitempty"`
+	Match         *MatchRecord `json:",omitempty"`
+}
+
+// MatchRecord remote or path that decided the user
+type MatchRecord struct {
+	Remote    string `json:",omitempty"`
+	RemoteURL string `json:",omitempty"`
+	Path      string `json:",omitempty"`
+}
+
+// SyncRecord result of sync printed as record
+type SyncRecord struct {
+	Path    string
+	Status  string
+	DryRun  bool           `json:",omitempty"`
+	User    *UserRecord    `json:",omitempty"`
+	Changes []ConfigChange `json:",omitempty"`
+	Errors  []string       `json:",omitempty"`
+}
+
+// NewUserRecord record of user, and of match if not nil
+func NewUserRecord(user *User, match *Match) *UserRecord {
+	record := &UserRecord{
+		Hash:          user.Hash(),
+		URL:           user.Rule(),
+		Profile:       user.Profile,
+		Name:          user.Name,
+		Email:         user.Email,
+		SigningKey:    user.SigningKey,
+		SigningFormat: user.SigningFormat,
+		SSHKey:        user.SSHKey,
+		Config:        user.Config,
+	}
+	if match != nil {
+		record.Match = &MatchRecord{Path: match.Path}
+		if match.Remote != nil {
+			record.Match = &MatchRecord{Remote: match.Remote.Name, RemoteURL: match.Remote.URL}
+		}
+	}
+	return record
+}
+
+// NewSyncRecord record of sync result
+func NewSyncRecord(result *SyncResult) *SyncRecord {
+	record := &SyncRecord{
+		Path:    result.Path,
+		Status:  result.Status,
+		DryRun:  result.DryRun,
+		Changes: result.Changes,
+	}
+	if result.Match != nil {
+		record.User = NewUserRecord(result.Match.User, result.Match)
+	}
+	for _, err := range result.Errors {
+		record.Errors = append(record.Errors, err.Error())
+	}
+	return record
+}
+
+// header and rows of tsv
+func (r *UserRecord) header() []string {
+	return []string{"hash", "url", "profile", "name", "email", "signingkey", "signingformat", "sshkey", "config", "remote", "remoteurl", "path"}
+}
+
+func (r *UserRecord) rows() [][]string {
+	var config []string
+	for _, entry := range r.Config {
+		config = append(config, entry.Key+"="+entry.Value)
+	}
+	match := r.Match
+	if match == nil {
+		match = &MatchRecord{}
+	}
+	return [][]string{{
+		r.Hash, r.URL, r.Profile, r.Name, r.Email, r.SigningKey, r.SigningFormat, r.SSHKey,
+		strings.Join(config, ","), match.Remote, match.RemoteURL, match.Path,
+	}}
+}
+
+func (r *SyncRecord) header() []string {
+	return []string{"path", "status", "dryrun", "hash", "url", "email", "key", "before", "after", "error"}
+}
+
+// rows a row per change and per error, or a row without them
+func (r *SyncRecord) rows() [][]string {
+	user := r.User
+	if user == nil {
+		user = &UserRecord{}
+	}
+	row := func(change ConfigChange, err string) []string {
+		return []string{r.Path, r.Status, strconv.FormatBool(r.DryRun), user.Hash, user.URL, user.Email, change.Key, change.Before, change.After, err}
+	}
+	var rows [][]string
+	for _, change := range r.Changes {
+		rows = append(rows, row(change, ""))
+	}
+	for _, err := range r.Errors {
+		rows = append(rows, row(ConfigChange{}, err))
+	}
+	if len(rows) == 0 {
+		rows = append(rows, row(ConfigChange{}, ""))
+	}
+	return rows
+}
+
+// record printed by recordEncoder
+type record interface {
+	header() []string
+	rows() [][]string
+}
+
+// recordEncoder backend of Printer writing records instead of text columns
+type recordEncoder interface {
+	// Encode write record, or records as list
+	Encode(w io.Writer, records []record, list bool) error
+}
+
+// newRecordEncoder encoder of output format. nil for text
+func newRecordEncoder(output string) recordEncoder {
+	switch output {
+	case FormatJSON, FormatYAML:
+		return &documentEncoder{format: output}
+	case OutputTSV:
+		return &tsvEncoder{}
+	}
+	return nil
+}
+
+// documentEncoder write records as json or yaml document
+type documentEncoder struct {
+	format string
+}
+
+// Encode write a document. list is array even if empty
+func (e *documentEncoder) Encode(w io.Writer, records []record, list bool) error {
+	var v interface{} = records
+	if !list && len(records) == 1 {
+		v = records[0]
+	} else if records == nil {
+		v = []record{}
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if data, err = fromJSON(append(data, '\n'), e.format, nil); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// tsvEncoder write records as tab separated values with header
+type tsvEncoder struct{}
+
+// Encode write header and rows. tab and line feed of values are escaped
+func (e *tsvEncoder) Encode(w io.Writer, records []record, list bool) error {
+	if len(records) == 0 {
+		return nil
+	}
+	lines := [][]string{records[0].header()}
+	for _, r := range records {
+		lines = append(lines, r.rows()...)
+	}
+	escape := strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r")
+	for _, line := range lines {
+		values := make([]string, len(line))
+		for i, value := range line {
+			values[i] = escape.Replace(value)
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(values, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"io"
-	"math/bits"
 	"strings"
 )
 
@@ -25,6 +24,10 @@ const (
 type Printer struct {
 	flag   uint
 	writer io.Writer
+	// encoder prints users and sync results as records instead of text columns if not nil
+	encoder recordEncoder
+	// messages writer of messages for human, writer if nil
+	messages io.Writer
 }
 
 // NewPrinter inti Printer
@@ -38,10 +41,36 @@ func NewPrinter(flag uint, writer io.Writer) *Printer {
 	}
 }
 
+// Output print records of output format into writer, and messages into messages. text is not changed
+func (p *Printer) Output(output string, messages io.Writer) *Printer {
+	p.encoder = newRecordEncoder(output)
+	if p.encoder != nil {
+		p.messages = messages
+	}
+	return p
+}
+
+func (p Printer) messageWriter() io.Writer {
+	if p.messages != nil {
+		return p.messages
+	}
+	return p.writer
+}
+
+func (p Printer) encode(records []record, list bool) Printer {
+	if err := p.encoder.Encode(p.writer, records, list); err != nil {
+		fmt.Fprintln(p.messageWriter(), err)
+	}
+	return p
+}
+
 func (p Printer) buf(user *User) []string {
 	var buf []string
 	if p.flag&PrintURL == PrintURL {
-		buf = append(buf, fmt.Sprintf("URL: %s", user.URL))
+		buf = append(buf, fmt.Sprintf("URL: %s", user.Rule()))
+	}
+	if p.flag == PrintALL && user.Profile != "" {
+		buf = append(buf, fmt.Sprintf("Profile: %s", user.Profile))
 	}
 	if p.flag&PrintName == PrintName {
 		buf = append(buf, fmt.Sprintf("Name: %s", user.Name))
@@ -50,7 +79,21 @@ func (p Printer) buf(user *User) []string {
 		buf = append(buf, fmt.Sprintf("Email: %s", user.Email))
 	}
 	if p.flag&PrintSigningKey == PrintSigningKey {
-		buf = append(buf, strings.TrimSpace(fmt.Sprintf("SigningKey: %s", user.SigningKey)))
+		signingKey := user.SigningKey
+		if user.SigningFormat != "" {
+			signingKey = fmt.Sprintf("%s (%s)", signingKey, user.SigningFormat)
+		}
+		buf = append(buf, strings.TrimSpace(fmt.Sprintf("SigningKey: %s", signingKey)))
+	}
+	if p.flag == PrintALL && user.SSHKey != "" {
+		buf = append(buf, fmt.Sprintf("SSHKey: %s", user.SSHKey))
+	}
+	if p.flag == PrintALL && len(user.Config) > 0 {
+		var config []string
+		for _, entry := range user.Config {
+			config = append(config, entry.Key+"="+entry.Value)
+		}
+		buf = append(buf, fmt.Sprintf("Config: %s", strings.Join(config, ", ")))
 	}
 	if p.flag == PrintALL {
 		buf = append(buf, fmt.Sprintf("Hash: %s", user.Hash()))
@@ -60,24 +103,59 @@ func (p Printer) buf(user *User) []string {
 
 // PrintUser print user
 func (p Printer) PrintUser(user *User) Printer {
+	if p.encoder != nil {
+		return p.encode([]record{NewUserRecord(user, nil)}, false)
+	}
 	fmt.Fprintln(p.writer, strings.Join(p.buf(user), "  "))
 	return p
 }
 
 // PrintUsers print users
 func (p Printer) PrintUsers(users []*User) Printer {
+	if p.encoder != nil {
+		records := make([]record, len(users))
+		for i, u := range users {
+			records[i] = NewUserRecord(u, nil)
+		}
+		return p.encode(records, true)
+	}
 	lines := make([][]string, len(users))
-	colMaxLen := make([]int, bits.OnesCount(p.flag)+1)
 	for i, u := range users {
 		lines[i] = p.buf(u)
-		for j, s := range lines[i] {
-			l := len(s)
-			if colMaxLen[j] < l {
+	}
+	return p.PrintTable(lines)
+}
+
+// PrintProfiles print profiles with number of rules referencing it
+func (p Printer) PrintProfiles(profiles Profiles, users Users) Printer {
+	lines := make([][]string, len(profiles))
+	for i, profile := range profiles {
+		lines[i] = []string{
+			fmt.Sprintf("Profile: %s", profile.Profile),
+			fmt.Sprintf("Name: %s", profile.Name),
+			fmt.Sprintf("Email: %s", profile.Email),
+			strings.TrimSpace(fmt.Sprintf("SigningKey: %s", profile.SigningKey)),
+			fmt.Sprintf("Rules: %d", len(users.ByProfile(profile.Profile))),
+		}
+	}
+	return p.PrintTable(lines)
+}
+
+// PrintTable print columns aligned
+func (p Printer) PrintTable(lines [][]string) Printer {
+	var colMaxLen []int
+	for _, line := range lines {
+		for j, s := range line {
+			if len(colMaxLen) <= j {
+				colMaxLen = append(colMaxLen, 0)
+			}
+			if l := len(s); colMaxLen[j] < l {
 				colMaxLen[j] = l
 			}
 		}
 	}
 
+	writer := p.messageWriter()
 	for _, line := range lines {
 		buf := make([]string, len(line))
 		for i, col := range line {
@@ -86,20 +164,99 @@ func (p Printer) PrintUsers(users []*User) Printer {
 				fmt.Sprintf("%-0"+fmt.Sprint(colMaxLen[i]+2)+"s", col),
 			)
 		}
-		fmt.Fprintln(p.writer, strings.TrimSpace(strings.Join(buf, "")))
+		fmt.Fprintln(writer, strings.TrimSpace(strings.Join(buf, "")))
 	}
 
 	return p
 }
 
+// PrintMatchedUser print user with remote or path that decided it
+func (p Printer) PrintMatchedUser(match *Match) Printer {
+	if p.encoder != nil {
+		return p.encode([]record{NewUserRecord(match.User, match)}, false)
+	}
+	return p.PrintUser(match.User).PrintMatch(match)
+}
+
+// PrintMatch print remote or path that decided the user. only with all items, and included in records
+func (p Printer) PrintMatch(match *Match) Printer {
+	if match == nil || p.flag != PrintALL || p.encoder != nil {
+		return p
+	}
+	if match.Remote != nil {
+		fmt.Fprintf(p.writer, "Remote: %s  URL: %s\n", match.Remote.Name, match.Remote.URL)
+	} else {
+		fmt.Fprintf(p.writer, "Path: %s\n", match.Path)
+	}
+	return p
+}
+
+// PrintSyncResult print changes of local git config as diff, and errors
+func (p Printer) PrintSyncResult(result *SyncResult) Printer {
+	if p.encoder != nil {
+		return p.encode([]record{NewSyncRecord(result)}, false)
+	}
+	for _, change := range result.Changes {
+		if change.Before != "" {
+			fmt.Fprintf(p.writer, "- %s = %s\n", change.Key, change.Before)
+		}
+		if change.After != "" {
+			fmt.Fprintf(p.writer, "+ %s = %s\n", change.Key, change.After)
+		}
+	}
+	for _, err := range result.Errors {
+		fmt.Fprintln(p.writer, err.Error())
+	}
+	if result.DryRun && len(result.Changes) > 0 {
+		fmt.Fprintln(p.writer, "dry-run. nothing is written")
+	}
+	return p
+}
+
+// PrintSyncResults print results of work trees as table and summary, then changes of each work tree
+func (p Printer) PrintSyncResults(results []*SyncResult) Printer {
+	if p.encoder != nil {
+		records := make([]record, len(results))
+		for i, result := range results {
+			records[i] = NewSyncRecord(result)
+		}
+		return p.encode(records, true)
+	}
+
+	counts := map[string]int{}
+	var rows [][]string
+	for _, result := range results {
+		counts[result.Status]++
+		row := []string{result.Path, result.Status}
+		if result.Match != nil {
+			row = append(row, result.Match.User.Email)
+		}
+		for _, err := range result.Errors {
+			row = append(row, err.Error())
+		}
+		rows = append(rows, row)
+	}
+	p.PrintTable(rows)
+	p.Printf("%d changed, %d unchanged, %d unmatched, %d error\n",
+		counts[SyncChanged], counts[SyncUnchanged], counts[SyncUnmatched], counts[SyncError])
+
+	for _, result := range results {
+		if len(result.Changes) > 0 {
+			p.Printf("\n%s\n", result.Path)
+			p.PrintSyncResult(result)
+		}
+	}
+	return p
+}
+
 // Println print message with line feed
 func (p Printer) Println(message string) Printer {
-	fmt.Fprintln(p.writer, message)
+	fmt.Fprintln(p.messageWriter(), message)
 	return p
 }
 
 // Printf print formatted message
 func (p Printer) Printf(format string, a ...interface{}) Printer {
-	fmt.Fprintf(p.writer, format, a...)
+	fmt.Fprintf(p.messageWriter(), format, a...)
 	return p
 }
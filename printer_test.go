@@ -105,6 +105,20 @@ func TestPrinter_PrintUser(t *testing.T) {
 			},
 			"Name: Mike Wazowski  Email: mike@example.com",
 		},
+		{
+			"with config",
+			fields{flag: PrintALL},
+			args{
+				&User{
+					URL:    "git@example.com:c/d",
+					Name:   "Mike Wazowski",
+					Email:  "mike@example.com",
+					Config: ConfigMap{{Key: "core.sshCommand", Value: "ssh -i ~/.ssh/work"}, {Key: "http.proxy", Value: "http://proxy:8080"}},
+				},
+			},
+			"URL: git@example.com:c/d  Name: Mike Wazowski  Email: mike@example.com  SigningKey:  " +
+				"Config: core.sshCommand=ssh -i ~/.ssh/work, http.proxy=http://proxy:8080  Hash: ",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -243,3 +257,141 @@ func TestPrinter_Println(t *testing.T) {
 		})
 	}
 }
+
+func TestPrinter_PrintSyncResult(t *testing.T) {
+	tests := []struct {
+		name       string
+		result     *SyncResult
+		wantWriter string
+	}{
+		{
+			"changes",
+			&SyncResult{
+				Changes: []ConfigChange{
+					{Key: "user.name", After: "Mike Wazowski"},
+					{Key: "user.email", Before: "mike@home.example.com", After: "mike@example.com"},
+					{Key: "user.signingkey", Before: "AAABBBCCC"},
+				},
+			},
+			`+ user.name = Mike Wazowski
+- user.email = mike@home.example.com
++ user.email = mike@example.com
+- user.signingkey = AAABBBCCC
+`,
+		},
+		{
+			"dry-run",
+			&SyncResult{
+				Changes: []ConfigChange{{Key: "user.name", After: "Mike Wazowski"}},
+				DryRun:  true,
+			},
+			"+ user.name = Mike Wazowski\ndry-run. nothing is written\n",
+		},
+		{
+			"no change",
+			&SyncResult{DryRun: true},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &bytes.Buffer{}
+			p := Printer{
+				flag:   PrintALL,
+				writer: writer,
+			}
+			p.PrintSyncResult(tt.result)
+			if got := writer.String(); got != tt.wantWriter {
+				t.Errorf("PrintSyncResult() write `%v`, want `%v`", got, tt.wantWriter)
+			}
+		})
+	}
+}
+
+func TestPrinter_Output(t *testing.T) {
+	user := &User{URL: "git@github.com:acme/*", Name: "Mike Wazowski", Email: "mike@example.com"}
+	match := &Match{User: user, Remote: &Remote{Name: "origin", URL: "git@github.com:acme/repo.git"}}
+	result := &SyncResult{
+		Path:    "/src/repo",
+		Match:   match,
+		Status:  SyncChanged,
+		Changes: []ConfigChange{{Key: "user.name", After: "Mike\tWazowski"}},
+	}
+	hash := user.Hash()
+	tests := []struct {
+		name         string
+		output       string
+		print        func(*Printer)
+		wantWriter   string
+		wantMessages string
+	}{
+		{
+			"text",
+			OutputText,
+			func(p *Printer) { p.PrintMatchedUser(match).Println("message") },
+			"URL: git@github.com:acme/*  Name: Mike Wazowski  Email: mike@example.com  SigningKey:  Hash: " + hash +
+				"\nRemote: origin  URL: git@github.com:acme/repo.git\nmessage\n",
+			"",
+		},
+		{
+			"json",
+			FormatJSON,
+			func(p *Printer) { p.PrintMatchedUser(match).Println("message") },
+			`{
+  "Hash": "` + hash + `",
+  "URL": "git@github.com:acme/*",
+  "Name": "Mike Wazowski",
+  "Email": "mike@example.com",
+  "Match": {
+    "Remote": "origin",
+    "RemoteURL": "git@github.com:acme/repo.git"
+  }
+}
+`,
+			"message\n",
+		},
+		{
+			"json empty list",
+			FormatJSON,
+			func(p *Printer) { p.PrintUsers(nil) },
+			"[]\n",
+			"",
+		},
+		{
+			"yaml",
+			FormatYAML,
+			func(p *Printer) { p.PrintUsers(Users{user}) },
+			"- Hash: " + hash + "\n  URL: git@github.com:acme/*\n  Name: Mike Wazowski\n  Email: mike@example.com\n",
+			"",
+		},
+		{
+			"tsv",
+			OutputTSV,
+			func(p *Printer) { p.PrintUser(user) },
+			"hash\turl\tprofile\tname\temail\tsigningkey\tsigningformat\tsshkey\tconfig\tremote\tremoteurl\tpath\n" +
+				hash + "\tgit@github.com:acme/*\t\tMike Wazowski\tmike@example.com\t\t\t\t\t\t\t\n",
+			"",
+		},
+		{
+			"tsv sync",
+			OutputTSV,
+			func(p *Printer) { p.PrintMatch(match).PrintSyncResult(result) },
+			"path\tstatus\tdryrun\thash\turl\temail\tkey\tbefore\tafter\terror\n" +
+				"/src/repo\tchanged\tfalse\t" + hash + "\tgit@github.com:acme/*\tmike@example.com\tuser.name\t\tMike\\tWazowski\t\n",
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer, messages := &bytes.Buffer{}, &bytes.Buffer{}
+			p := NewPrinter(PrintDefault, writer).Output(tt.output, messages)
+			tt.print(p)
+			if got := writer.String(); got != tt.wantWriter {
+				t.Errorf("Output() write `%v`, want `%v`", got, tt.wantWriter)
+			}
+			if got := messages.String(); got != tt.wantMessages {
+				t.Errorf("Output() messages `%v`, want `%v`", got, tt.wantMessages)
+			}
+		})
+	}
+}
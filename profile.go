@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Identity is git identity of profile
+type Identity struct {
+	Name       string
+	Email      string
+	SigningKey string `json:",omitempty"`
+
+	SigningFormat      string `json:",omitempty"`
+	GPGSign            bool   `json:",omitempty"`
+	TagGPGSign         bool   `json:",omitempty"`
+	AllowedSignersFile string `json:",omitempty"`
+	GPGProgram         string `json:",omitempty"`
+
+	SSHKey string    `json:",omitempty"`
+	Config ConfigMap `json:",omitempty"`
+}
+
+// Same is identity identical
+func (id Identity) Same(o Identity) bool {
+	if len(id.Config) == 0 && len(o.Config) == 0 {
+		id.Config, o.Config = nil, nil
+	}
+	return reflect.DeepEqual(id, o)
+}
+
+// Identity of user
+func (u *User) Identity() Identity {
+	return Identity{
+		Name:               u.Name,
+		Email:              u.Email,
+		SigningKey:         u.SigningKey,
+		SigningFormat:      u.SigningFormat,
+		GPGSign:            u.GPGSign,
+		TagGPGSign:         u.TagGPGSign,
+		AllowedSignersFile: u.AllowedSignersFile,
+		GPGProgram:         u.GPGProgram,
+		SSHKey:             u.SSHKey,
+		Config:             u.Config,
+	}
+}
+
+// SetIdentity replace identity of user
+func (u *User) SetIdentity(id Identity) {
+	u.Name = id.Name
+	u.Email = id.Email
+	u.SigningKey = id.SigningKey
+	u.SigningFormat = id.SigningFormat
+	u.GPGSign = id.GPGSign
+	u.TagGPGSign = id.TagGPGSign
+	u.AllowedSignersFile = id.AllowedSignersFile
+	u.GPGProgram = id.GPGProgram
+	u.SSHKey = id.SSHKey
+	u.Config = id.Config
+}
+
+// rule copy of user without identity, which is saved with profile
+func (u *User) rule() *User {
+	r := *u
+	r.SetIdentity(Identity{})
+	return &r
+}
+
+// Profile is named identity referenced by rules
+type Profile struct {
+	Profile string
+	Identity
+}
+
+// Valid validate profile name
+func (p *Profile) Valid() error {
+	if p.Profile == "" || strings.IndexFunc(p.Profile, func(r rune) bool { return r <= ' ' }) >= 0 {
+		return fmt.Errorf("invalid profile name %q", p.Profile)
+	}
+	return nil
+}
+
+// Profiles profiles
+type Profiles []*Profile
+
+// Get profile by name
+func (ps Profiles) Get(name string) *Profile {
+	for _, p := range ps {
+		if p.Profile == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// Put append or update by name
+func (ps *Profiles) Put(np *Profile) *Profile {
+	for i, p := range *ps {
+		if p.Profile == np.Profile {
+			(*ps)[i] = np
+			return np
+		}
+	}
+	*ps = append(*ps, np)
+	return np
+}
+
+// Delete delete profile if exists
+func (ps *Profiles) Delete(name string) *Profile {
+	var dp *Profile
+	var nps Profiles
+	for _, p := range *ps {
+		if p.Profile == name {
+			dp = p
+		} else {
+			nps = append(nps, p)
+		}
+	}
+	*ps = nps
+
+	return dp
+}
+
+// ForIdentity profile having identity. new profile named after email is appended if none
+func (ps *Profiles) ForIdentity(id Identity) *Profile {
+	for _, p := range *ps {
+		if p.Identity.Same(id) {
+			return p
+		}
+	}
+
+	base := id.Email
+	if base == "" {
+		base = "profile"
+	}
+	name := base
+	for i := 2; ps.Get(name) != nil; i++ {
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+	return ps.Put(&Profile{Profile: name, Identity: id})
+}
+
+// Valid validate names of all profiles
+func (ps Profiles) Valid() error {
+	seen := map[string]bool{}
+	for _, p := range ps {
+		if err := p.Valid(); err != nil {
+			return err
+		}
+		if seen[p.Profile] {
+			return fmt.Errorf("duplicate profile %s", p.Profile)
+		}
+		seen[p.Profile] = true
+	}
+	return nil
+}
+
+// Resolve copy identity of profile into users. users without profile, saved by older version, are migrated into profiles
+func (ps *Profiles) Resolve(us Users) (migrated bool, err error) {
+	for _, u := range us {
+		if u.Profile == "" {
+			u.Profile = ps.ForIdentity(u.Identity()).Profile
+			migrated = true
+			continue
+		}
+		if err := ps.resolve(u); err != nil {
+			return migrated, err
+		}
+	}
+	return migrated, nil
+}
+
+func (ps Profiles) resolve(u *User) error {
+	p := ps.Get(u.Profile)
+	if p == nil {
+		return fmt.Errorf("profile %s of rule %s is not found", u.Profile, u.Rule())
+	}
+	u.SetIdentity(p.Identity)
+	return nil
+}
+
+// Rename rename profile and rules referencing it
+func (ps Profiles) Rename(us Users, name, to string) error {
+	p := ps.Get(name)
+	if p == nil {
+		return fmt.Errorf("profile %s is not found", name)
+	}
+	if ps.Get(to) != nil {
+		return fmt.Errorf("profile %s already exists", to)
+	}
+	p.Profile = to
+	for _, u := range us.ByProfile(name) {
+		u.Profile = to
+	}
+	return p.Valid()
+}
+
+// ByProfile users referencing profile
+func (us Users) ByProfile(name string) Users {
+	var found Users
+	for _, u := range us {
+		if u.Profile == name {
+			found = append(found, u)
+		}
+	}
+	return found
+}
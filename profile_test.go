@@ -0,0 +1,94 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProfiles_ForIdentity(t *testing.T) {
+	profiles := Profiles{
+		&Profile{Profile: "work", Identity: Identity{Name: "Mike", Email: "mike@acme.com", Config: ConfigMap{}}},
+		&Profile{Profile: "mike@example.com", Identity: Identity{Name: "Mike", Email: "mike@example.com"}},
+	}
+	tests := []struct {
+		name     string
+		identity Identity
+		want     string
+	}{
+		{"same identity", Identity{Name: "Mike", Email: "mike@acme.com"}, "work"},
+		{"new identity", Identity{Name: "Mike", Email: "mike@oss.org"}, "mike@oss.org"},
+		{"unique name", Identity{Name: "Mike", Email: "mike@example.com", SigningKey: "ABCD"}, "mike@example.com-2"},
+		{"without email", Identity{Name: "Mike"}, "profile"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := profiles.ForIdentity(tt.identity); got.Profile != tt.want {
+				t.Errorf("ForIdentity() = %v, want %v", got.Profile, tt.want)
+			}
+		})
+	}
+	if len(profiles) != 5 {
+		t.Errorf("ForIdentity() appended %d profiles, want 3", len(profiles)-2)
+	}
+}
+
+func TestProfiles_Resolve(t *testing.T) {
+	profiles := Profiles{
+		&Profile{Profile: "work", Identity: Identity{Name: "Mike", Email: "mike@acme.com"}},
+	}
+	users := Users{
+		&User{URL: "git@github.com:acme/*", Profile: "work"},
+		&User{URL: "git@gitlab.com:acme/*", Name: "Mike", Email: "mike@acme.com"},
+		&User{URL: "git@github.com:*", Name: "Mike", Email: "mike@example.com"},
+	}
+	migrated, err := profiles.Resolve(users)
+	if err != nil || !migrated {
+		t.Fatalf("Resolve() = %v, %v", migrated, err)
+	}
+	var got [][2]string
+	for _, u := range users {
+		got = append(got, [2]string{u.Profile, u.Email})
+	}
+	want := [][2]string{{"work", "mike@acme.com"}, {"work", "mike@acme.com"}, {"mike@example.com", "mike@example.com"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Resolve() users = %v, want %v", got, want)
+	}
+
+	if migrated, _ := profiles.Resolve(users); migrated {
+		t.Errorf("Resolve() migrated resolved users")
+	}
+	if _, err := profiles.Resolve(Users{&User{URL: "git@github.com:*", Profile: "oss"}}); err == nil {
+		t.Errorf("Resolve() no error with missing profile")
+	}
+}
+
+func TestProfiles_Rename(t *testing.T) {
+	profiles := Profiles{
+		&Profile{Profile: "mike@acme.com"},
+		&Profile{Profile: "oss"},
+	}
+	users := Users{
+		&User{URL: "git@github.com:acme/*", Profile: "mike@acme.com"},
+		&User{URL: "git@github.com:oss/*", Profile: "oss"},
+	}
+	if err := profiles.Rename(users, "mike@acme.com", "oss"); err == nil {
+		t.Errorf("Rename() no error with existing name")
+	}
+	if err := profiles.Rename(users, "mike@acme.com", "work"); err != nil {
+		t.Fatalf("Rename() error = %v", err)
+	}
+	if profiles.Get("work") == nil || users[0].Profile != "work" || users[1].Profile != "oss" {
+		t.Errorf("Rename() profiles %v, users %v %v", profiles, users[0].Profile, users[1].Profile)
+	}
+}
+
+func TestUser_rule(t *testing.T) {
+	user := &User{URL: "git@github.com:*", Profile: "work", Priority: 1, Name: "Mike", Email: "mike@acme.com", SSHKey: "~/.ssh/id_work"}
+	want := &User{URL: "git@github.com:*", Profile: "work", Priority: 1}
+	if got := user.rule(); !reflect.DeepEqual(got, want) {
+		t.Errorf("rule() = %+v, want %+v", got, want)
+	}
+	if user.Name != "Mike" {
+		t.Errorf("rule() modified user")
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/ryanuber/go-glob"
+)
+
+// shared rule file name in repository
+const sharedConfigBase = ".git-user"
+
+// import mode
+const (
+	ImportMerge   = "merge"
+	ImportReplace = "replace"
+)
+
+// import status
+const (
+	ImportAdded     = "added"
+	ImportUnchanged = "unchanged"
+	ImportConflict  = "conflict"
+	ImportReplaced  = "replaced"
+)
+
+// ImportResult result of importing rule or profile
+type ImportResult struct {
+	Rule   string
+	Status string
+	Detail string
+}
+
+// environment variables of TemplateData. others are not exposed to shared files
+var templateEnv = []string{"USER", "USERNAME", "LOGNAME", "HOME"}
+
+// TemplateData data of templated fields in shared file, e.g. `{{.Local.Email}}`
+type TemplateData struct {
+	// Local identity of global git config
+	Local Identity
+	// Env environment variables of templateEnv
+	Env map[string]string
+}
+
+// NewTemplateData template data of identity of global git config
+func NewTemplateData(local Identity) *TemplateData {
+	data := &TemplateData{Local: local, Env: map[string]string{}}
+	for _, name := range templateEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			data.Env[name] = value
+		}
+	}
+	return data
+}
+
+// sharedConfigPath shared file, or .git-user file of directory
+func sharedConfigPath(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return path, nil
+	}
+	if file, ok := existingConfig(filepath.Join(path, sharedConfigBase), legacyConfigExts); ok {
+		return file, nil
+	}
+	return "", fmt.Errorf("%s has no %s.json, .yaml, .yml or .toml", path, sharedConfigBase)
+}
+
+// ExportDocument document of users matched with rule glob, and profiles of them
+func ExportDocument(users Users, profiles Profiles, filter string) *document {
+	doc := &document{}
+	for _, user := range users {
+		if filter != "" && !glob.Glob(filter, user.Rule()) {
+			continue
+		}
+		if user.Profile == "" {
+			doc.Users = append(doc.Users, user)
+			continue
+		}
+		if doc.Profiles.Get(user.Profile) == nil {
+			doc.Profiles = append(doc.Profiles, profiles.Get(user.Profile))
+		}
+		doc.Users = append(doc.Users, user.rule())
+	}
+	return doc
+}
+
+// RenderTemplates execute templated fields of identities
+func RenderTemplates(doc *document, data *TemplateData) error {
+	identities := make([]*Identity, 0, len(doc.Profiles)+len(doc.Users))
+	for _, p := range doc.Profiles {
+		identities = append(identities, &p.Identity)
+	}
+	var inline []Identity
+	for _, u := range doc.Users {
+		if u.Profile == "" {
+			inline = append(inline, u.Identity())
+		}
+	}
+	for i := range inline {
+		identities = append(identities, &inline[i])
+	}
+
+	for _, id := range identities {
+		fields := []*string{&id.Name, &id.Email, &id.SigningKey, &id.AllowedSignersFile, &id.GPGProgram, &id.SSHKey}
+		for i := range id.Config {
+			fields = append(fields, &id.Config[i].Value)
+		}
+		for _, field := range fields {
+			value, err := renderTemplate(*field, data)
+			if err != nil {
+				return err
+			}
+			*field = value
+		}
+	}
+
+	i := 0
+	for _, u := range doc.Users {
+		if u.Profile == "" {
+			u.SetIdentity(inline[i])
+			i++
+		}
+	}
+	return nil
+}
+
+func renderTemplate(text string, data *TemplateData) (string, error) {
+	t, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Import merge profiles and users of document. local ones win conflicts with merge mode, imported ones with replace mode.
+// rules may reference local profile not in document
+func Import(profiles *Profiles, users *Users, doc *document, mode string) ([]ImportResult, error) {
+	for _, u := range doc.Users {
+		if u.Profile == "" {
+			u.Profile = doc.Profiles.ForIdentity(u.Identity()).Profile
+		}
+	}
+
+	var results []ImportResult
+	renamed := map[string]string{}
+	for _, p := range doc.Profiles {
+		local := profiles.Get(p.Profile)
+		switch {
+		case local == nil:
+			profiles.Put(p)
+			results = append(results, ImportResult{Rule: "profile:" + p.Profile, Status: ImportAdded,
+				Detail: commandChanges(Identity{}, p.Identity)})
+		case local.Identity.Same(p.Identity):
+			results = append(results, ImportResult{Rule: "profile:" + p.Profile, Status: ImportUnchanged})
+		case mode == ImportReplace:
+			profiles.Put(p)
+			for _, u := range users.ByProfile(p.Profile) {
+				u.SetIdentity(p.Identity)
+			}
+			results = append(results, ImportResult{Rule: "profile:" + p.Profile, Status: ImportReplaced,
+				Detail: withChanges(fmt.Sprintf("%s <%s> -> %s <%s>", local.Name, local.Email, p.Name, p.Email), commandChanges(local.Identity, p.Identity))})
+		default:
+			// imported profile is kept under new name not to change local rules
+			renamed[p.Profile] = profiles.ForIdentity(p.Identity).Profile
+			results = append(results, ImportResult{Rule: "profile:" + p.Profile, Status: ImportConflict,
+				Detail: withChanges(fmt.Sprintf("local %s <%s>, imported %s <%s> as %s", local.Name, local.Email, p.Name, p.Email, renamed[p.Profile]),
+					commandChanges(local.Identity, p.Identity))})
+		}
+	}
+
+	for _, u := range doc.Users {
+		if name, ok := renamed[u.Profile]; ok {
+			u.Profile = name
+		}
+		if err := profiles.resolve(u); err != nil {
+			return nil, err
+		}
+		if err := u.Valid(); err != nil {
+			return nil, fmt.Errorf("%s: %v", u.Rule(), err)
+		}
+
+		var local *User
+		for _, lu := range *users {
+			if lu.SameRule(u) {
+				local = lu
+			}
+		}
+		switch {
+		case local == nil:
+			users.Put(u)
+			results = append(results, ImportResult{Rule: u.Rule(), Status: ImportAdded, Detail: u.Profile})
+		case reflect.DeepEqual(local.rule(), u.rule()):
+			results = append(results, ImportResult{Rule: u.Rule(), Status: ImportUnchanged, Detail: u.Profile})
+		case mode == ImportReplace:
+			users.Put(u)
+			results = append(results, ImportResult{Rule: u.Rule(), Status: ImportReplaced,
+				Detail: withChanges(fmt.Sprintf("%s <%s> -> %s <%s>", local.Name, local.Email, u.Name, u.Email), commandChanges(local.Identity(), u.Identity()))})
+		default:
+			results = append(results, ImportResult{Rule: u.Rule(), Status: ImportConflict,
+				Detail: fmt.Sprintf("local %s <%s>, imported %s <%s> is skipped", local.Name, local.Email, u.Name, u.Email)})
+		}
+	}
+	return results, nil
+}
+
+// commandChanges changes of fields running commands or configuring git, e.g. gpg program and core.sshCommand,
+// reported by import to be reviewed
+func commandChanges(local, imported Identity) string {
+	var changes []string
+	change := func(field, before, after string) {
+		if before != after {
+			changes = append(changes, fmt.Sprintf("%s %q -> %q", field, before, after))
+		}
+	}
+	change("sshkey", local.SSHKey, imported.SSHKey)
+	change("gpgprogram", local.GPGProgram, imported.GPGProgram)
+	for _, entry := range imported.Config {
+		before, _ := local.Config.Get(entry.Key)
+		change(entry.Key, before, entry.Value)
+	}
+	for _, entry := range local.Config {
+		if _, ok := imported.Config.Get(entry.Key); !ok {
+			change(entry.Key, entry.Value, "")
+		}
+	}
+	return strings.Join(changes, ", ")
+}
+
+// withChanges detail followed by changes
+func withChanges(detail, changes string) string {
+	if changes == "" {
+		return detail
+	}
+	return detail + "; " + changes
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExportDocument(t *testing.T) {
+	profiles := Profiles{
+		&Profile{Profile: "work", Identity: Identity{Name: "Mike", Email: "mike@acme.com"}},
+		&Profile{Profile: "personal", Identity: Identity{Name: "Mike", Email: "mike@example.com"}},
+	}
+	users := Users{
+		&User{URL: "git@github.com:acme/*", Profile: "work", Name: "Mike", Email: "mike@acme.com"},
+		&User{URL: "git@gitlab.com:acme/*", Profile: "work", Name: "Mike", Email: "mike@acme.com"},
+		&User{URL: "git@github.com:*", Profile: "personal", Name: "Mike", Email: "mike@example.com"},
+		&User{URL: "git@bitbucket.org:acme/*", Name: "Shared", Email: "shared@acme.com"},
+	}
+	got := ExportDocument(users, profiles, "*acme*")
+	want := &document{
+		Profiles: Profiles{profiles[0]},
+		Users: Users{
+			&User{URL: "git@github.com:acme/*", Profile: "work"},
+			&User{URL: "git@gitlab.com:acme/*", Profile: "work"},
+			users[3],
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExportDocument() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRenderTemplates(t *testing.T) {
+	doc := &document{
+		Profiles: Profiles{&Profile{Profile: "acme", Identity: Identity{Name: "{{.Local.Name}}", Email: "{{.Env.USER}}@acme.com",
+			Config: ConfigMap{{Key: "credential.username", Value: "{{.Env.USER}}"}}}}},
+		Users: Users{&User{URL: "git@github.com:*", Name: "{{.Local.Name}}", Email: "{{.Local.Email}}"}},
+	}
+	data := &TemplateData{Local: Identity{Name: "Mike", Email: "mike@example.com"}, Env: map[string]string{"USER": "mike"}}
+	if err := RenderTemplates(doc, data); err != nil {
+		t.Fatalf("RenderTemplates() error = %v", err)
+	}
+	p := doc.Profiles[0]
+	if p.Name != "Mike" || p.Email != "mike@acme.com" || p.Config[0].Value != "mike" {
+		t.Errorf("RenderTemplates() profile = %+v", p)
+	}
+	if u := doc.Users[0]; u.Name != "Mike" || u.Email != "mike@example.com" {
+		t.Errorf("RenderTemplates() user = %+v", u)
+	}
+
+	doc = &document{Users: Users{&User{URL: "git@github.com:*", Email: "{{.Env.MISSING}}"}}}
+	if err := RenderTemplates(doc, data); err == nil {
+		t.Errorf("RenderTemplates() no error with missing key")
+	}
+}
+
+func TestImport(t *testing.T) {
+	newDocument := func() *document {
+		return &document{
+			Profiles: Profiles{
+				&Profile{Profile: "work", Identity: Identity{Name: "Mike", Email: "mike@new.acme.com"}},
+			},
+			Users: Users{
+				&User{URL: "git@github.com:acme/*", Profile: "work"},
+				&User{URL: "git@gitlab.com:acme/*", Profile: "personal"},
+				&User{URL: "git@github.com:*", Profile: "personal"},
+			},
+		}
+	}
+	tests := []struct {
+		name  string
+		mode  string
+		want  []string
+		email string
+	}{
+		{"merge", ImportMerge, []string{ImportConflict, ImportConflict, ImportAdded, ImportUnchanged}, "mike@acme.com"},
+		{"replace", ImportReplace, []string{ImportReplaced, ImportUnchanged, ImportAdded, ImportUnchanged}, "mike@new.acme.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profiles := Profiles{
+				&Profile{Profile: "work", Identity: Identity{Name: "Mike", Email: "mike@acme.com"}},
+				&Profile{Profile: "personal", Identity: Identity{Name: "Mike", Email: "mike@example.com"}},
+			}
+			users := Users{
+				&User{URL: "git@github.com:acme/*", Profile: "work"},
+				&User{URL: "git@github.com:*", Profile: "personal"},
+			}
+			profiles.Resolve(users)
+
+			results, err := Import(&profiles, &users, newDocument(), tt.mode)
+			if err != nil {
+				t.Fatalf("Import() error = %v", err)
+			}
+			var got []string
+			for _, result := range results {
+				got = append(got, result.Status)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Import() = %v, want %v", results, tt.want)
+			}
+			if len(users) != 3 || users[0].Email != tt.email || users[2].Email != "mike@example.com" {
+				t.Errorf("Import() users = %+v %+v %+v", users[0], users[1], users[2])
+			}
+		})
+	}
+}
+
+func TestNewTemplateData(t *testing.T) {
+	defer os.Setenv("USER", os.Getenv("USER"))
+	os.Setenv("USER", "mike")
+	defer os.Unsetenv("GIT_USER_TEST_SECRET")
+	os.Setenv("GIT_USER_TEST_SECRET", "secret")
+
+	data := NewTemplateData(Identity{Name: "Mike"})
+	if data.Env["USER"] != "mike" {
+		t.Errorf("NewTemplateData() USER = %v", data.Env["USER"])
+	}
+	if _, ok := data.Env["GIT_USER_TEST_SECRET"]; ok {
+		t.Errorf("NewTemplateData() exposed environment not allowed")
+	}
+}
+
+func TestImport_commandChanges(t *testing.T) {
+	profiles := Profiles{
+		&Profile{Profile: "work", Identity: Identity{Name: "Mike", Email: "mike@acme.com", Config: ConfigMap{{Key: "http.proxy", Value: "http://proxy:8080"}}}},
+	}
+	users := Users{&User{URL: "git@github.com:acme/*", Profile: "work"}}
+	profiles.Resolve(users)
+	doc := &document{
+		Profiles: Profiles{
+			&Profile{Profile: "work", Identity: Identity{Name: "Mike", Email: "mike@acme.com", GPGProgram: "/tmp/gpg",
+				Config: ConfigMap{{Key: "core.sshCommand", Value: "ssh -i ~/.ssh/acme"}}}},
+			&Profile{Profile: "oss", Identity: Identity{Name: "Mike", Email: "mike@example.com", SSHKey: "~/.ssh/id_oss"}},
+		},
+	}
+	results, err := Import(&profiles, &users, doc, ImportReplace)
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	want := []string{
+		`Mike <mike@acme.com> -> Mike <mike@acme.com>; gpgprogram "" -> "/tmp/gpg", core.sshCommand "" -> "ssh -i ~/.ssh/acme", http.proxy "http://proxy:8080" -> ""`,
+		`sshkey "" -> "~/.ssh/id_oss"`,
+	}
+	var got []string
+	for _, result := range results {
+		got = append(got, result.Detail)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Import() details = %q, want %q", got, want)
+	}
+}
+
+func Test_sharedConfigPath(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "git-user_test")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, ".git-user.yaml")
+	ioutil.WriteFile(file, nil, 0644)
+
+	if got, err := sharedConfigPath(dir); got != file || err != nil {
+		t.Errorf("sharedConfigPath() = %v, %v, want %v", got, err, file)
+	}
+	if got, err := sharedConfigPath(file); got != file || err != nil {
+		t.Errorf("sharedConfigPath() = %v, %v, want %v", got, err, file)
+	}
+	if _, err := sharedConfigPath(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("sharedConfigPath() no error with missing path")
+	}
+	os.Remove(file)
+	if _, err := sharedConfigPath(dir); err == nil {
+		t.Errorf("sharedConfigPath() no error without shared file")
+	}
+}
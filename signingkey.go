@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// sshLiteralKeyPrefix prefix of literal ssh public key in user.signingkey
+const sshLiteralKeyPrefix = "key::"
+
+// ValidSigningKey check signing key exists. gpg and gpgsm keys must be in secret keyring,
+// ssh key must be readable file unless literal public key
+func (u *User) ValidSigningKey() error {
+	if u.SigningKey == "" {
+		return nil
+	}
+
+	switch u.SigningFormat {
+	case SigningSSH:
+		return validSSHSigningKey(u.SigningKey)
+	case SigningX509:
+		return validGPGSigningKey(u.gpgProgram("gpgsm"), u.SigningKey)
+	}
+	return validGPGSigningKey(u.gpgProgram("gpg"), u.SigningKey)
+}
+
+// Diagnose all problems of rule, signing profile and ssh key
+func (u *User) Diagnose() []error {
+	var errs []error
+	if err := u.Valid(); err != nil {
+		errs = append(errs, err)
+	}
+	if (u.GPGSign || u.TagGPGSign) && u.SigningKey == "" {
+		errs = append(errs, errors.New("signing is enabled without signing key"))
+	}
+	if err := u.ValidSigningKey(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := u.ValidSSHKey(); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func (u *User) gpgProgram(program string) string {
+	if u.GPGProgram != "" {
+		return u.GPGProgram
+	}
+	return program
+}
+
+func validSSHSigningKey(key string) error {
+	if strings.HasPrefix(key, sshLiteralKeyPrefix) || strings.HasPrefix(key, "ssh-") {
+		return nil
+	}
+	path, err := homedir.Expand(key)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("ssh signing key %s is not readable: %v", key, err)
+	}
+	return f.Close()
+}
+
+func validGPGSigningKey(program, key string) error {
+	stderr := &bytes.Buffer{}
+	cmd := exec.Command(program, "--batch", "--list-secret-keys", "--", key)
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+			return fmt.Errorf("signing key %s is not found in secret keyring of %s: %s", key, program, lines[len(lines)-1])
+		}
+		return fmt.Errorf("can not list secret keys with %s: %v", program, err)
+	}
+	return nil
+}
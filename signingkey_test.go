@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUser_ValidSigningKey(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "git-user_test")
+	defer os.RemoveAll(dir)
+	publicKey := filepath.Join(dir, "id_ed25519.pub")
+	ioutil.WriteFile(publicKey, []byte("ssh-ed25519 AAAA mike@example.com\n"), 0644)
+	os.Setenv("GNUPGHOME", dir)
+	defer os.Unsetenv("GNUPGHOME")
+
+	tests := []struct {
+		name    string
+		user    *User
+		wantErr bool
+	}{
+		{"no signing key", &User{}, false},
+		{"ssh key file", &User{SigningKey: publicKey, SigningFormat: SigningSSH}, false},
+		{"ssh literal key", &User{SigningKey: "key::ssh-ed25519 AAAA", SigningFormat: SigningSSH}, false},
+		{"ssh missing key file", &User{SigningKey: filepath.Join(dir, "missing.pub"), SigningFormat: SigningSSH}, true},
+		{"gpg missing key", &User{SigningKey: "0123456789ABCDEF"}, true},
+		{"missing gpg program", &User{SigningKey: "0123456789ABCDEF", GPGProgram: filepath.Join(dir, "gpg")}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.user.ValidSigningKey(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidSigningKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUser_Diagnose(t *testing.T) {
+	tests := []struct {
+		name string
+		user *User
+		want int
+	}{
+		{"ok", &User{URL: "git@github.com:*", Name: "Mike", Email: "mike@example.com"}, 0},
+		{"invalid rule", &User{URL: "[", Type: MatchRegex}, 1},
+		{"gpgsign without signing key", &User{URL: "git@github.com:*", GPGSign: true}, 1},
+		{"missing ssh key", &User{Type: MatchPath, SigningKey: "/nonexistent.pub", SigningFormat: SigningSSH}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.user.Diagnose(); len(got) != tt.want {
+				t.Errorf("Diagnose() = %v, want %d errors", got, tt.want)
+			}
+		})
+	}
+}
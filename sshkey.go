@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// sshCommandKey git config key of ssh command
+const sshCommandKey = "core.sshCommand"
+
+var shellSafePattern = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// SSHCommand core.sshCommand using only ssh key of user
+func (u *User) SSHCommand() string {
+	if u.SSHKey == "" {
+		return ""
+	}
+	key, err := homedir.Expand(u.SSHKey)
+	if err != nil {
+		key = u.SSHKey
+	}
+	if !shellSafePattern.MatchString(key) {
+		key = shellQuote(key)
+	}
+	return fmt.Sprintf("ssh -i %s -o IdentitiesOnly=yes", key)
+}
+
+// ValidSSHKey check ssh key file is readable
+func (u *User) ValidSSHKey() error {
+	if u.SSHKey == "" {
+		return nil
+	}
+	path, err := homedir.Expand(u.SSHKey)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("ssh key %s is not readable: %v", u.SSHKey, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// sync status
+const (
+	SyncChanged   = "changed"
+	SyncUnchanged = "unchanged"
+	SyncUnmatched = "unmatched"
+	SyncError     = "error"
+)
+
+// ConfigChange is change of local git config key. empty value is unset
+type ConfigChange struct {
+	Key    string
+	Before string
+	After  string
+}
+
+// SyncResult result of sync to local git config
+type SyncResult struct {
+	Path    string
+	Match   *Match
+	Status  string
+	Changes []ConfigChange
+	Errors  []error
+	DryRun  bool
+}
+
+// SyncRepository sync user matched with repository to local git config.
+// managed keys are unset if nothing matched, unless repository has no remote. extra config keys of any user are unset
+// only if the value is written by git-user. with dryRun, nothing is written.
+// nothing is written either if signing key or ssh key of matched user does not exist. unset key is not error
+func SyncRepository(git GitBackend, repo *Repository, users Users, dryRun bool) *SyncResult {
+	return syncRepository(git, repo, users, dryRun, true)
+}
+
+// syncRepository sync like SyncRepository. signing key and ssh key are not validated unless validKeys,
+// e.g. for print of prompt not running gpg
+func syncRepository(git GitBackend, repo *Repository, users Users, dryRun, validKeys bool) *SyncResult {
+	result := &SyncResult{
+		Path:   repo.Path,
+		Match:  users.TakeByRepository(repo),
+		Status: SyncUnchanged,
+		DryRun: dryRun,
+	}
+
+	want := map[string]string{}
+	if result.Match != nil {
+		user := result.Match.User
+		if validKeys {
+			for _, valid := range []func() error{user.ValidSigningKey, user.ValidSSHKey} {
+				if err := valid(); err != nil {
+					result.Status = SyncError
+					result.Errors = append(result.Errors, err)
+				}
+			}
+			if result.Status == SyncError {
+				return result
+			}
+		}
+		for _, entry := range user.GitConfig() {
+			want[canonicalConfigKey(entry.Key)] = entry.Value
+		}
+	} else {
+		result.Status = SyncUnmatched
+		if len(repo.Remotes) == 0 {
+			return result
+		}
+	}
+
+	for _, key := range users.ConfigKeys() {
+		current, err := git.GetLocalConfig(key)
+		if err != nil && !IsNotFound(err) {
+			result.Errors = append(result.Errors, err)
+			result.Status = SyncError
+			continue
+		}
+		value := want[canonicalConfigKey(key)]
+		if current == value || value == "" && !users.OwnsConfig(key, current) {
+			continue
+		}
+
+		result.Changes = append(result.Changes, ConfigChange{Key: key, Before: current, After: value})
+		if result.Status == SyncUnchanged {
+			result.Status = SyncChanged
+		}
+		if dryRun {
+			continue
+		}
+
+		if value != "" {
+			err = git.SetLocalConfig(key, value)
+		} else if err = git.UnsetLocalConfig(key); IsNotFound(err) {
+			err = nil
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, err)
+			result.Status = SyncError
+		}
+	}
+
+	return result
+}
+
+// FindWorkTrees find git work trees under root, including nested repositories, worktrees and submodules
+func FindWorkTrees(root string) ([]string, error) {
+	var paths []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsPermission(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Name() != ".git" {
+			return nil
+		}
+		paths = append(paths, filepath.Dir(path))
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// groupByCommonDir group work trees by common git dir having config file, in order of paths
+func groupByCommonDir(paths []string) [][]string {
+	index := map[string]int{}
+	var groups [][]string
+	for _, path := range paths {
+		key := path
+		if repo, err := discoverGitRepository(path); err == nil {
+			key = repo.CommonDir
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], path)
+	}
+	return groups
+}
+
+// SyncWorkTrees sync work trees concurrently by bounded workers. results are sorted by path.
+// work trees sharing config file, e.g. main and linked worktrees, are synced one after another
+func SyncWorkTrees(paths []string, jobs int, syncFunc func(path string) *SyncResult) []*SyncResult {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	queue := make(chan []string)
+	results := make([]*SyncResult, 0, len(paths))
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range queue {
+				for _, path := range group {
+					result := syncFunc(path)
+					mutex.Lock()
+					results = append(results, result)
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+	for _, group := range groupByCommonDir(paths) {
+		queue <- group
+	}
+	close(queue)
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Path < results[j].Path
+	})
+	return results
+}
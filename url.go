@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+)
+
+// RepoURL is normalized repository URL
+type RepoURL struct {
+	Host  string
+	Owner string
+	Path  string
+}
+
+// String normalized form `host/path`
+func (r *RepoURL) String() string {
+	if r.Path == "" {
+		return r.Host
+	}
+	return r.Host + "/" + r.Path
+}
+
+// ParseRepoURL parse ssh, scp-like, https, git:// and normalized `host/path` URL.
+// user info, port and `.git` suffix are stripped
+func ParseRepoURL(raw string) (*RepoURL, bool) {
+	var host, path string
+	if i := strings.Index(raw, "://"); i >= 0 {
+		rest := raw[i+3:]
+		if j := strings.Index(rest, "/"); j >= 0 {
+			host, path = rest[:j], rest[j+1:]
+		} else {
+			host = rest
+		}
+		if j := strings.LastIndex(host, "@"); j >= 0 {
+			host = host[j+1:]
+		}
+		if j := strings.LastIndex(host, ":"); j >= 0 && !strings.HasSuffix(host, "]") {
+			host = host[:j]
+		}
+	} else if i := strings.Index(raw, ":"); i >= 0 && !strings.Contains(raw[:i], "/") {
+		host, path = raw[:i], raw[i+1:]
+		if j := strings.LastIndex(host, "@"); j >= 0 {
+			host = host[j+1:]
+		}
+	} else if i := strings.Index(raw, "/"); i > 0 && !strings.HasPrefix(raw, ".") {
+		host, path = raw[:i], raw[i+1:]
+	} else {
+		return nil, false
+	}
+
+	if host == "" {
+		return nil, false
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	owner := path
+	if i := strings.Index(path, "/"); i >= 0 {
+		owner = path[:i]
+	}
+
+	return &RepoURL{
+		Host:  strings.ToLower(host),
+		Owner: owner,
+		Path:  path,
+	}, true
+}
+
+// NormalizeURL normalized form of URL or URL pattern. return raw if not parsable
+func NormalizeURL(raw string) string {
+	if repo, ok := ParseRepoURL(raw); ok {
+		return repo.String()
+	}
+	return raw
+}
@@ -4,18 +4,32 @@ import (
 	"crypto/sha1"
 	"fmt"
 	"sort"
-
-	"github.com/ryanuber/go-glob"
+	"strings"
 )
 
 const userHashSie = 7
 
 // User is git user conf
 type User struct {
-	URL        string
-	Name       string
-	Email      string
-	SigningKey string
+	URL        string `json:",omitempty"`
+	Profile    string `json:",omitempty"`
+	Name       string `json:",omitempty"`
+	Email      string `json:",omitempty"`
+	SigningKey string `json:",omitempty"`
+	Priority   int    `json:",omitempty"`
+	Type       string `json:",omitempty"`
+	Host       string `json:",omitempty"`
+	Owner      string `json:",omitempty"`
+	Repo       string `json:",omitempty"`
+
+	SigningFormat      string `json:",omitempty"`
+	GPGSign            bool   `json:",omitempty"`
+	TagGPGSign         bool   `json:",omitempty"`
+	AllowedSignersFile string `json:",omitempty"`
+	GPGProgram         string `json:",omitempty"`
+
+	SSHKey string    `json:",omitempty"`
+	Config ConfigMap `json:",omitempty"`
 }
 
 // Hash is identity of user
@@ -24,6 +38,37 @@ func (u *User) Hash() string {
 	return h[0:userHashSie]
 }
 
+// Compare rule precedence. negative if u precedes o. reason describes the decision
+func (u *User) Compare(o *User) (int, string) {
+	if u.Priority != o.Priority {
+		h, l := sortPair(u.Priority, o.Priority)
+		return o.Priority - u.Priority, fmt.Sprintf("priority %d > %d", h, l)
+	}
+	ul, us, uw := u.Specificity()
+	ol, os, ow := o.Specificity()
+	if ul != ol {
+		h, l := sortPair(ul, ol)
+		return ol - ul, fmt.Sprintf("more literal characters %d > %d", h, l)
+	}
+	if us != os {
+		h, l := sortPair(us, os)
+		return os - us, fmt.Sprintf("more literal segments %d > %d", h, l)
+	}
+	if uw != ow {
+		h, l := sortPair(uw, ow)
+		return uw - ow, fmt.Sprintf("fewer wildcards %d < %d", l, h)
+	}
+	return strings.Compare(o.Rule(), u.Rule()), "same specificity, rule sorts later"
+}
+
+// sortPair return higher and lower
+func sortPair(a, b int) (int, int) {
+	if a < b {
+		return b, a
+	}
+	return a, b
+}
+
 // PrintUsers is slice of user
 type Users []*User
 
@@ -34,7 +79,8 @@ func (us Users) Len() int {
 
 // Less sort.Interface
 func (us Users) Less(i, j int) bool {
-	return us[i].URL > us[j].URL
+	c, _ := us[i].Compare(us[j])
+	return c < 0
 }
 
 // Swap sort.Interface
@@ -42,18 +88,76 @@ func (us Users) Swap(i, j int) {
 	us[i], us[j] = us[j], us[i]
 }
 
-// TakeByURL find user by repository URL
-func (us Users) TakeByURL(url string) *User {
-	sort.Sort(us)
-	for _, user := range us {
-		if glob.Glob(user.URL, url) {
-			return user
+// Repository is a work tree to match users
+type Repository struct {
+	Path    string
+	Remotes Remotes
+}
+
+// Match is user matched by remote or by path
+type Match struct {
+	User   *User
+	Remote *Remote
+	Path   string
+}
+
+// Compare match precedence. priority first, remote order next (path rules follow remotes), rule precedence last
+func (m *Match) Compare(o *Match, remotes Remotes) (int, string) {
+	if m.User.Priority != o.User.Priority {
+		return m.User.Compare(o.User)
+	}
+	mi, oi := remotes.index(m.Remote), remotes.index(o.Remote)
+	if mi != oi {
+		reason := fmt.Sprintf("%s precedes %s", m.source(), o.source())
+		if mi > oi {
+			reason = fmt.Sprintf("%s precedes %s", o.source(), m.source())
 		}
+		return mi - oi, reason
 	}
-	return nil
+	return m.User.Compare(o.User)
+}
+
+func (m *Match) source() string {
+	if m.Remote == nil {
+		return "path rule"
+	}
+	return "remote " + m.Remote.Name
+}
+
+// MatchRepository find all users matched by remotes or path of repository, ranked by precedence
+func (us Users) MatchRepository(repo *Repository) []*Match {
+	var matches []*Match
+	for _, remote := range repo.Remotes {
+		for _, user := range us {
+			if user.MatchType() != MatchPath && user.MatchURL(remote.URL) {
+				matches = append(matches, &Match{User: user, Remote: remote})
+			}
+		}
+	}
+	if repo.Path != "" {
+		for _, user := range us {
+			if user.MatchType() == MatchPath && user.MatchPath(repo.Path) {
+				matches = append(matches, &Match{User: user, Path: repo.Path})
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		c, _ := matches[i].Compare(matches[j], repo.Remotes)
+		return c < 0
+	})
+	return matches
+}
+
+// TakeByRepository find user by remotes or path of repository
+func (us Users) TakeByRepository(repo *Repository) *Match {
+	matches := us.MatchRepository(repo)
+	if len(matches) == 0 {
+		return nil
+	}
+	return matches[0]
 }
 
-// TakeByURL find user by user hash
+// TakeByHash find user by user hash
 func (us Users) TakeByHash(hash string) *User {
 	for _, user := range us {
 		if user.Hash() == hash {
@@ -65,26 +169,36 @@ func (us Users) TakeByHash(hash string) *User {
 
 // Set append or update
 func (us *Users) Set(url, name, email, signingkey string) *User {
-	nu := &User{
+	return us.Put(&User{
 		URL:        url,
 		Name:       name,
 		Email:      email,
 		SigningKey: signingkey,
-	}
+	})
+}
 
-	updated := false
-	for _, user := range *us {
-		if user.URL == url {
-			updated = true
-			user = nu
+// Put append or update by rule
+func (us *Users) Put(nu *User) *User {
+	for i, user := range *us {
+		if user.SameRule(nu) {
+			(*us)[i] = nu
+			return nu
 		}
 	}
-	if !updated {
-		*us = append(*us, nu)
-	}
+	*us = append(*us, nu)
 	return nu
 }
 
+// Valid validate rules of all users
+func (us Users) Valid() error {
+	for _, user := range us {
+		if err := user.Valid(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Delete delete user if exists
 func (us *Users) Delete(hash string) *User {
 	var du *User
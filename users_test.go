@@ -90,7 +90,7 @@ func TestUser_Hash(t *testing.T) {
 	}
 }
 
-func TestUsers_FindByURL(t *testing.T) {
+func TestUsers_TakeByRepository_url(t *testing.T) {
 	type args struct {
 		url string
 	}
@@ -125,6 +125,22 @@ func TestUsers_FindByURL(t *testing.T) {
 			args{"git@github.com:tsuty/git-user.git"},
 			&User{URL: "git@github.com:tsuty/git-user.git", Name: "Completely"},
 		},
+		{
+			"scp-like rule match https",
+			Users{
+				&User{URL: "git@github.com:tsuty/*"},
+			},
+			args{"https://github.com/tsuty/git-user.git"},
+			&User{URL: "git@github.com:tsuty/*"},
+		},
+		{
+			"normalized rule match ssh",
+			Users{
+				&User{URL: "github.com/tsuty/*"},
+			},
+			args{"ssh://git@github.com/tsuty/git-user"},
+			&User{URL: "github.com/tsuty/*"},
+		},
 		{
 			"not found",
 			Users{
@@ -137,8 +153,12 @@ func TestUsers_FindByURL(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := tt.us.TakeByURL(tt.args.url); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("TakeByURL() = %v, want %v", got, tt.want)
+			var got *User
+			if match := tt.us.TakeByRepository(&Repository{Remotes: Remotes{{Name: "origin", URL: tt.args.url}}}); match != nil {
+				got = match.User
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TakeByRepository() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -318,3 +338,170 @@ func TestUsers_Delete(t *testing.T) {
 		})
 	}
 }
+
+func TestUsers_TakeByRepository_remotes(t *testing.T) {
+	us := Users{
+		&User{URL: "git@github.com:acme/*", Name: "Company"},
+		&User{URL: "git@github.com:tsuty/*", Name: "Personal"},
+	}
+	tests := []struct {
+		name       string
+		remotes    Remotes
+		want       *User
+		wantRemote string
+	}{
+		{
+			"first remote match",
+			Remotes{
+				&Remote{Name: "upstream", URL: "git@github.com:acme/repo.git"},
+				&Remote{Name: "origin", URL: "git@github.com:tsuty/repo.git"},
+			},
+			us[0],
+			"upstream",
+		},
+		{
+			"second remote match",
+			Remotes{
+				&Remote{Name: "upstream", URL: "git@gitlab.com:acme/repo.git"},
+				&Remote{Name: "origin", URL: "git@github.com:tsuty/repo.git"},
+			},
+			us[1],
+			"origin",
+		},
+		{
+			"not found",
+			Remotes{
+				&Remote{Name: "origin", URL: "git@gitlab.com:acme/repo.git"},
+			},
+			nil,
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *User
+			var gotRemote string
+			if match := us.TakeByRepository(&Repository{Remotes: tt.remotes}); match != nil {
+				got, gotRemote = match.User, match.Remote.Name
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TakeByRepository() = %v, want %v", got, tt.want)
+			}
+			if gotRemote != tt.wantRemote {
+				t.Errorf("TakeByRepository() remote = %v, want %v", gotRemote, tt.wantRemote)
+			}
+		})
+	}
+}
+
+func TestUser_Compare(t *testing.T) {
+	tests := []struct {
+		name       string
+		u          *User
+		o          *User
+		wantFirst  bool
+		wantReason string
+	}{
+		{
+			"priority wins over specificity",
+			&User{URL: "git@github.com:*", Priority: 10},
+			&User{URL: "git@github.com:acme/*"},
+			true,
+			"priority 10 > 0",
+		},
+		{
+			"more literal characters",
+			&User{URL: "git@github.com:*"},
+			&User{URL: "git@github.com:acme/*"},
+			false,
+			"more literal characters 16 > 11",
+		},
+		{
+			"fewer wildcards",
+			&User{URL: "git@github.com:acme/a*b*"},
+			&User{URL: "git@github.com:acme/*ab"},
+			false,
+			"fewer wildcards 1 < 2",
+		},
+		{
+			"independent of leading character",
+			&User{URL: "*@github.com:acme/*"},
+			&User{URL: "git@*"},
+			true,
+			"more literal characters 16 > 4",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := tt.u.Compare(tt.o)
+			if (got < 0) != tt.wantFirst {
+				t.Errorf("Compare() = %v, want first %v", got, tt.wantFirst)
+			}
+			if reason != tt.wantReason {
+				t.Errorf("Compare() reason = %v, want %v", reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestUsers_MatchRepository(t *testing.T) {
+	us := Users{
+		&User{URL: "git@github.com:*", Name: "GitHub"},
+		&User{URL: "git@github.com:acme/*", Name: "Company"},
+		&User{URL: "git@gitlab.com:*", Name: "GitLab"},
+		&User{URL: "/home/tsuty/work/*", Type: MatchPath, Name: "Work"},
+		&User{URL: "/home/tsuty/oss/*", Type: MatchPath, Name: "OSS", Priority: 1},
+	}
+	tests := []struct {
+		name string
+		repo *Repository
+		want []string
+	}{
+		{
+			"remote order then specificity",
+			&Repository{
+				Remotes: Remotes{
+					&Remote{Name: "upstream", URL: "git@github.com:acme/repo.git"},
+					&Remote{Name: "origin", URL: "git@gitlab.com:tsuty/repo.git"},
+				},
+			},
+			[]string{"Company", "GitHub", "GitLab"},
+		},
+		{
+			"path rule follows remotes",
+			&Repository{
+				Path: "/home/tsuty/work/repo",
+				Remotes: Remotes{
+					&Remote{Name: "origin", URL: "git@github.com:tsuty/repo.git"},
+				},
+			},
+			[]string{"GitHub", "Work"},
+		},
+		{
+			"path rule without remote",
+			&Repository{Path: "/home/tsuty/work/repo"},
+			[]string{"Work"},
+		},
+		{
+			"priority path rule precedes remotes",
+			&Repository{
+				Path: "/home/tsuty/oss/repo",
+				Remotes: Remotes{
+					&Remote{Name: "origin", URL: "git@github.com:acme/repo.git"},
+				},
+			},
+			[]string{"OSS", "Company", "GitHub"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, m := range us.MatchRepository(tt.repo) {
+				got = append(got, m.User.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MatchRepository() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// wildmatch match text with pattern like git wildmatch with WM_PATHNAME, used by includeIf conditions.
+// `*`, `?` and bracket expression do not match `/`, and `**` between slashes matches any directories
+func wildmatch(pattern, text string, fold bool) bool {
+	if fold {
+		pattern, text = strings.ToLower(pattern), strings.ToLower(text)
+	}
+	return wildmatchAt(pattern, text, true)
+}
+
+// wildmatchAt match from start of path segment if segmentStart
+func wildmatchAt(pattern, text string, segmentStart bool) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			if strings.HasPrefix(pattern, "**") && segmentStart && (len(pattern) == 2 || pattern[2] == '/') {
+				if len(pattern) == 2 {
+					return true
+				}
+				// `**/` matches zero or more directories
+				rest := pattern[3:]
+				for {
+					if wildmatchAt(rest, text, true) {
+						return true
+					}
+					i := strings.IndexByte(text, '/')
+					if i < 0 {
+						return false
+					}
+					text = text[i+1:]
+				}
+			}
+			pattern = strings.TrimLeft(pattern, "*")
+			for i := 0; ; i++ {
+				if wildmatchAt(pattern, text[i:], false) {
+					return true
+				}
+				if i == len(text) || text[i] == '/' {
+					return false
+				}
+			}
+		case '?':
+			r, size := utf8.DecodeRuneInString(text)
+			if size == 0 || r == '/' {
+				return false
+			}
+			pattern, text = pattern[1:], text[size:]
+			segmentStart = false
+		case '[':
+			r, size := utf8.DecodeRuneInString(text)
+			if size == 0 || r == '/' {
+				return false
+			}
+			matched, rest, ok := matchBracket(pattern, r)
+			if !ok {
+				// unclosed bracket is literal
+				if text[0] != '[' {
+					return false
+				}
+				pattern, text = pattern[1:], text[1:]
+				segmentStart = false
+				break
+			}
+			if !matched {
+				return false
+			}
+			pattern, text = rest, text[size:]
+			segmentStart = false
+		default:
+			c := pattern[0]
+			if c == '\\' && len(pattern) > 1 {
+				pattern = pattern[1:]
+				c = pattern[0]
+			}
+			if len(text) == 0 || text[0] != c {
+				return false
+			}
+			pattern, text = pattern[1:], text[1:]
+			segmentStart = c == '/'
+		}
+	}
+	return len(text) == 0
+}
+
+// matchBracket match rune with bracket expression at head of pattern, e.g. `[a-z]`, `[!0-9]`, `[[:alpha:]]`
+func matchBracket(pattern string, r rune) (matched bool, rest string, ok bool) {
+	i := 1
+	negate := false
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		negate = true
+		i++
+	}
+	for first := true; i < len(pattern); first = false {
+		if pattern[i] == ']' && !first {
+			return matched != negate, pattern[i+1:], true
+		}
+		if strings.HasPrefix(pattern[i:], "[:") {
+			if end := strings.Index(pattern[i+2:], ":]"); end >= 0 {
+				if matchCharClass(pattern[i+2:i+2+end], r) {
+					matched = true
+				}
+				i += end + 4
+				continue
+			}
+		}
+		lo, size := utf8.DecodeRuneInString(pattern[i:])
+		if lo == '\\' && i+size < len(pattern) {
+			i += size
+			lo, size = utf8.DecodeRuneInString(pattern[i:])
+		}
+		i += size
+		hi := lo
+		if i+1 < len(pattern) && pattern[i] == '-' && pattern[i+1] != ']' {
+			var hsize int
+			hi, hsize = utf8.DecodeRuneInString(pattern[i+1:])
+			i += 1 + hsize
+		}
+		if lo <= r && r <= hi {
+			matched = true
+		}
+	}
+	return false, pattern, false
+}
+
+func matchCharClass(class string, r rune) bool {
+	switch class {
+	case "alnum":
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	case "alpha":
+		return unicode.IsLetter(r)
+	case "digit":
+		return unicode.IsDigit(r)
+	case "lower":
+		return unicode.IsLower(r)
+	case "upper":
+		return unicode.IsUpper(r)
+	case "space":
+		return unicode.IsSpace(r)
+	case "punct":
+		return unicode.IsPunct(r)
+	case "xdigit":
+		return strings.ContainsRune("0123456789abcdefABCDEF", r)
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func Test_wildmatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		text    string
+		fold    bool
+		want    bool
+	}{
+		{"/home/me/work/**", "/home/me/work/repo/.git", false, true},
+		{"/home/me/work/**", "/home/me/work", false, false},
+		{"**/repo/**", "/home/me/repo/.git", false, true},
+		{"**/repo/**", "/home/me/other/.git", false, false},
+		{"a/**/b", "a/b", false, true},
+		{"a/**/b", "a/x/y/b", false, true},
+		{"a/*/b", "a/x/y/b", false, false},
+		{"*@github.com:acme/**", "git@github.com:acme/repo.git", false, true},
+		{"*@github.com:acme/*", "git@github.com:acme/group/repo.git", false, false},
+		{"http*://github.com/acme/**", "https://github.com/acme/repo", false, true},
+		{"feature/?", "feature/x", false, true},
+		{"feature/[a-c]", "feature/b", false, true},
+		{"feature/[!a-c]", "feature/b", false, false},
+		{"feature/[[:digit:]]", "feature/1", false, true},
+		{"**/REPO/**", "/home/me/repo/.git", true, true},
+		{"**/REPO/**", "/home/me/repo/.git", false, false},
+		{`a\*`, "a*", false, true},
+		{`a\*`, "ab", false, false},
+	}
+	for _, tt := range tests {
+		if got := wildmatch(tt.pattern, tt.text, tt.fold); got != tt.want {
+			t.Errorf("wildmatch(%q, %q, %v) = %v, want %v", tt.pattern, tt.text, tt.fold, got, tt.want)
+		}
+	}
+}